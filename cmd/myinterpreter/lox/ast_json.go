@@ -0,0 +1,368 @@
+package lox
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+type jsonObject = map[string]interface{}
+
+// jsonBuilder turns nodes into maps ready to be encoded as JSON, every node
+// has a "type" naming its Go type and a "span"
+type jsonBuilder struct{}
+
+func (jb jsonBuilder) node(nodeType string, span scanner.Span, fields jsonObject) jsonObject {
+	fields["type"] = nodeType
+	fields["span"] = span
+	return fields
+}
+
+func (jb jsonBuilder) token(t scanner.Token) jsonObject {
+	return jsonObject{
+		"type":   t.TokenType.String(),
+		"lexeme": t.Lexeme,
+		"line":   t.Line,
+		"column": t.Column,
+		"offset": t.Offset,
+	}
+}
+
+func (jb jsonBuilder) VisitLiteralExpr(l Literal) jsonObject {
+	return jb.node("Literal", l.Span(), jsonObject{"value": l.Value})
+}
+
+func (jb jsonBuilder) VisitGroupingExpr(g Grouping) jsonObject {
+	return jb.node("Grouping", g.Span(), jsonObject{"expression": AcceptExpr[jsonObject](g.Expression, jb)})
+}
+
+func (jb jsonBuilder) VisitUnaryExpr(u Unary) jsonObject {
+	return jb.node("Unary", u.Span(), jsonObject{
+		"operator": jb.token(u.Operator),
+		"right":    AcceptExpr[jsonObject](u.Right, jb),
+	})
+}
+
+func (jb jsonBuilder) VisitBinaryExpr(b Binary) jsonObject {
+	return jb.node("Binary", b.Span(), jsonObject{
+		"left":     AcceptExpr[jsonObject](b.Left, jb),
+		"operator": jb.token(b.Operator),
+		"right":    AcceptExpr[jsonObject](b.Right, jb),
+	})
+}
+
+func (jb jsonBuilder) VisitConditionalExpr(c Conditional) jsonObject {
+	return jb.node("Conditional", c.Span(), jsonObject{
+		"condition": AcceptExpr[jsonObject](c.Condition, jb),
+		"then":      AcceptExpr[jsonObject](c.Then, jb),
+		"else":      AcceptExpr[jsonObject](c.Else, jb),
+	})
+}
+
+func (jb jsonBuilder) VisitVariableExpr(v Variable) jsonObject {
+	return jb.node("Variable", v.Span(), jsonObject{"name": jb.token(v.Name)})
+}
+
+func (jb jsonBuilder) VisitCallExpr(c Call) jsonObject {
+	arguments := []jsonObject{}
+	for _, argument := range c.Arguments {
+		arguments = append(arguments, AcceptExpr[jsonObject](argument, jb))
+	}
+
+	return jb.node("Call", c.Span(), jsonObject{
+		"callee":    AcceptExpr[jsonObject](c.Callee, jb),
+		"paren":     jb.token(c.Paren),
+		"arguments": arguments,
+	})
+}
+
+func (jb jsonBuilder) VisitInterpolationExpr(i Interpolation) jsonObject {
+	parts := []jsonObject{}
+	for _, part := range i.Parts {
+		parts = append(parts, AcceptExpr[jsonObject](part, jb))
+	}
+
+	return jb.node("Interpolation", i.Span(), jsonObject{"parts": parts})
+}
+
+func (jb jsonBuilder) VisitExpressionStmt(e Expression) jsonObject {
+	return jb.node("Expression", e.Span(), jsonObject{"expression": AcceptExpr[jsonObject](e.Expression, jb)})
+}
+
+func (jb jsonBuilder) VisitPrintStmt(p Print) jsonObject {
+	return jb.node("Print", p.Span(), jsonObject{"expression": AcceptExpr[jsonObject](p.Expression, jb)})
+}
+
+func MarshalExpr(expr Expr) ([]byte, error) {
+	return json.MarshalIndent(AcceptExpr[jsonObject](expr, jsonBuilder{}), "", "  ")
+}
+
+func MarshalStmts(statements []Stmt) ([]byte, error) {
+	nodes := []jsonObject{}
+	for _, stmt := range statements {
+		nodes = append(nodes, AcceptStmt[jsonObject](stmt, jsonBuilder{}))
+	}
+
+	return json.MarshalIndent(nodes, "", "  ")
+}
+
+// jsonNode holds the fields of any node, only those of its type are set
+type jsonNode struct {
+	Type       string            `json:"type"`
+	Span       scanner.Span      `json:"span"`
+	Value      json.RawMessage   `json:"value"`
+	Expression json.RawMessage   `json:"expression"`
+	Left       json.RawMessage   `json:"left"`
+	Operator   *jsonToken        `json:"operator"`
+	Right      json.RawMessage   `json:"right"`
+	Parts      []json.RawMessage `json:"parts"`
+	Condition  json.RawMessage   `json:"condition"`
+	Then       json.RawMessage   `json:"then"`
+	Else       json.RawMessage   `json:"else"`
+	Name       *jsonToken        `json:"name"`
+	Callee     json.RawMessage   `json:"callee"`
+	Paren      *jsonToken        `json:"paren"`
+	Arguments  []json.RawMessage `json:"arguments"`
+}
+
+type jsonToken struct {
+	Type   string `json:"type"`
+	Lexeme string `json:"lexeme"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+}
+
+// the operators the parser builds unary and binary expressions with, the
+// interpreter cannot evaluate any other
+var (
+	unaryOperators = map[scanner.TokenType]bool{scanner.BANG: true, scanner.MINUS: true}
+
+	binaryOperators = map[scanner.TokenType]bool{
+		scanner.COMMA:      true,
+		scanner.BANG_EQUAL: true, scanner.EQUAL_EQUAL: true,
+		scanner.GREATER: true, scanner.GREATER_EQUAL: true, scanner.LESS: true, scanner.LESS_EQUAL: true,
+		scanner.MINUS: true, scanner.PLUS: true, scanner.SLASH: true, scanner.STAR: true,
+	}
+)
+
+// operator is the token of an operator, which must be one of allowed
+func (jt *jsonToken) operator(allowed map[scanner.TokenType]bool) (scanner.Token, error) {
+	t, err := jt.token()
+	if err != nil {
+		return scanner.Token{}, err
+	}
+
+	if !allowed[t.TokenType] {
+		return scanner.Token{}, fmt.Errorf("invalid operator %s", t.TokenType)
+	}
+
+	return t, nil
+}
+
+func (jt *jsonToken) token() (scanner.Token, error) {
+	if jt == nil {
+		return scanner.Token{}, fmt.Errorf("missing operator")
+	}
+
+	tokenType, ok := scanner.LookupTokenType(jt.Type)
+	if !ok {
+		return scanner.Token{}, fmt.Errorf("unknown token type %q", jt.Type)
+	}
+
+	return scanner.Token{
+		TokenType: tokenType,
+		Lexeme:    jt.Lexeme,
+		Line:      jt.Line,
+		Column:    jt.Column,
+		Offset:    jt.Offset,
+	}, nil
+}
+
+// UnmarshalExpr is the inverse of MarshalExpr, spans are optional
+func UnmarshalExpr(data []byte) (Expr, error) {
+	var node jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case "Literal":
+		var value interface{}
+		if len(node.Value) > 0 {
+			if err := json.Unmarshal(node.Value, &value); err != nil {
+				return nil, err
+			}
+		}
+
+		switch value.(type) {
+		case nil, bool, float64, string:
+		default:
+			return nil, fmt.Errorf("invalid literal value %s", node.Value)
+		}
+
+		literal := NewLiteral(value)
+		literal.span = node.Span
+		return literal, nil
+	case "Grouping":
+		expression, err := UnmarshalExpr(node.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		grouping := NewGrouping(expression)
+		grouping.span = node.Span
+		return grouping, nil
+	case "Unary":
+		operator, err := node.Operator.operator(unaryOperators)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := UnmarshalExpr(node.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewUnary(operator, right), nil
+	case "Binary":
+		left, err := UnmarshalExpr(node.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		operator, err := node.Operator.operator(binaryOperators)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := UnmarshalExpr(node.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBinary(left, operator, right), nil
+	case "Conditional":
+		condition, err := UnmarshalExpr(node.Condition)
+		if err != nil {
+			return nil, err
+		}
+
+		then, err := UnmarshalExpr(node.Then)
+		if err != nil {
+			return nil, err
+		}
+
+		els, err := UnmarshalExpr(node.Else)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewConditional(condition, then, els), nil
+	case "Variable":
+		name, err := node.Name.token()
+		if err != nil {
+			return nil, err
+		}
+
+		return NewVariable(name), nil
+	case "Call":
+		callee, err := UnmarshalExpr(node.Callee)
+		if err != nil {
+			return nil, err
+		}
+
+		paren, err := node.Paren.token()
+		if err != nil {
+			return nil, err
+		}
+
+		arguments := []Expr{}
+		for _, raw := range node.Arguments {
+			argument, err := UnmarshalExpr(raw)
+			if err != nil {
+				return nil, err
+			}
+
+			arguments = append(arguments, argument)
+		}
+
+		return NewCall(callee, paren, arguments), nil
+	case "Interpolation":
+		if len(node.Parts)%2 == 0 {
+			return nil, fmt.Errorf("interpolation needs an odd number of parts, got %d", len(node.Parts))
+		}
+
+		parts := []Expr{}
+		for n, raw := range node.Parts {
+			part, err := UnmarshalExpr(raw)
+			if err != nil {
+				return nil, err
+			}
+
+			if n%2 == 0 {
+				literal, _ := part.(Literal)
+				if _, ok := literal.Value.(string); !ok {
+					return nil, fmt.Errorf("interpolation part %d must be a string literal", n)
+				}
+			}
+
+			parts = append(parts, part)
+		}
+
+		return NewInterpolation(parts), nil
+	}
+
+	return nil, fmt.Errorf("unknown expression type %q", node.Type)
+}
+
+func unmarshalStmt(data []byte) (Stmt, error) {
+	var node jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case "Expression":
+		expression, err := UnmarshalExpr(node.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt := NewExpression(expression)
+		stmt.span = node.Span
+		return stmt, nil
+	case "Print":
+		expression, err := UnmarshalExpr(node.Expression)
+		if err != nil {
+			return nil, err
+		}
+
+		stmt := NewPrint(expression)
+		stmt.span = node.Span
+		return stmt, nil
+	}
+
+	return nil, fmt.Errorf("unknown statement type %q", node.Type)
+}
+
+// UnmarshalStmts is the inverse of MarshalStmts
+func UnmarshalStmts(data []byte) ([]Stmt, error) {
+	var nodes []json.RawMessage
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		return nil, err
+	}
+
+	statements := []Stmt{}
+	for _, raw := range nodes {
+		stmt, err := unmarshalStmt(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, stmt)
+	}
+
+	return statements, nil
+}
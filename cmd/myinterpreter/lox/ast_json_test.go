@@ -0,0 +1,71 @@
+package lox
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	source := "print -(1.5 + 2) * 3 != \"a${true}b${nil}\";\n!false == (\"s\");\nnil ? 1, 2 : 3;\nf(x, 1)();"
+	sc := scanner.NewScanner([]byte(source))
+	sc.Tokenize()
+	p := NewParser(sc.GetTokens())
+	statements, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalStmts(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := UnmarshalStmts(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, statements) {
+		t.Errorf("UnmarshalStmts(MarshalStmts()) = %+v, want %+v", got, statements)
+	}
+}
+
+func TestUnmarshalExprLiterals(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{data: `{"type": "Literal", "value": 1}`, want: 1.0},
+		{data: `{"type": "Literal", "value": "1"}`, want: "1"},
+		{data: `{"type": "Literal", "value": false}`, want: false},
+		{data: `{"type": "Literal", "value": null}`, want: nil},
+		{data: `{"type": "Literal"}`, want: nil},
+	}
+
+	for _, test := range tests {
+		expr, err := UnmarshalExpr([]byte(test.data))
+		if err != nil {
+			t.Fatalf("UnmarshalExpr(%s) error = %v", test.data, err)
+		}
+
+		if got := expr.(Literal).Value; got != test.want {
+			t.Errorf("UnmarshalExpr(%s) = %#v, want %#v", test.data, got, test.want)
+		}
+	}
+
+	for _, data := range []string{
+		`{"type": "Literal", "value": [1]}`,
+		`{"type": "Binary", "left": {"type": "Literal"}, "right": {"type": "Literal"}}`,
+		`{"type": "Unary", "operator": {"type": "NOPE"}, "right": {"type": "Literal"}}`,
+		`{"type": "Interpolation", "parts": [{"type": "Literal", "value": 1}]}`,
+		`{"type": "Variable"}`,
+		`{"type": "Binary", "left": {"type": "Literal"}, "operator": {"type": "LEFT_PAREN", "lexeme": "("}, "right": {"type": "Literal"}}`,
+		`{"type": "Unary", "operator": {"type": "PLUS", "lexeme": "+"}, "right": {"type": "Literal"}}`,
+	} {
+		if _, err := UnmarshalExpr([]byte(data)); err == nil {
+			t.Errorf("UnmarshalExpr(%s) error = nil, want error", data)
+		}
+	}
+}
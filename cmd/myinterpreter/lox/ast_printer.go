@@ -17,7 +17,7 @@ func (ap AstPrinter) parenthesize(name string, exprs ...Expr) string {
 
 	for _, expr := range exprs {
 		str += " "
-		str += expr.Accept(ap).(string)
+		str += AcceptExpr[string](expr, ap)
 	}
 
 	str += ")"
@@ -25,60 +25,63 @@ func (ap AstPrinter) parenthesize(name string, exprs ...Expr) string {
 }
 
 func (ap AstPrinter) Print(expr Expr) string {
-	return expr.Accept(ap).(string)
+	return AcceptExpr[string](expr, ap)
+}
+
+func (ap AstPrinter) PrintStmt(stmt Stmt) string {
+	return AcceptStmt[string](stmt, ap)
 }
 
 // literal        → NUMBER | STRING | "true" | "false" | "nil" ;
-func (ap AstPrinter) visitLiteralExpr(l Expr) interface{} {
-	var ret string
-	if ll, ok := l.(Literal); ok {
-		if ll.Value == nil {
-			ret = "nil"
-		} else {
-			ret = fmt.Sprintf("%v", scanner.HandleNumberLiteral(ll.Value))
-		}
+func (ap AstPrinter) VisitLiteralExpr(l Literal) string {
+	if l.Value == nil {
+		return "nil"
 	}
 
-	return ret
+	return fmt.Sprintf("%v", scanner.HandleNumberLiteral(l.Value))
 }
 
-func (ap AstPrinter) visitGroupingExpr(g Expr) interface{} {
-	var ret string
-	if gg, ok := g.(Grouping); ok {
-		ret = ap.parenthesize("group", gg.Expression)
-	}
+func (ap AstPrinter) VisitGroupingExpr(g Grouping) string {
+	return ap.parenthesize("group", g.Expression)
+}
 
-	return ret
+func (ap AstPrinter) VisitUnaryExpr(u Unary) string {
+	return ap.parenthesize(u.Operator.Lexeme, u.Right)
 }
 
-func (ap AstPrinter) visitUnaryExpr(u Expr) interface{} {
-	var ret string
-	if uu, ok := u.(Unary); ok {
-		ret = ap.parenthesize(uu.Operator.Lexeme, uu.Right)
-	}
+func (ap AstPrinter) VisitBinaryExpr(b Binary) string {
+	return ap.parenthesize(b.Operator.Lexeme, b.Left, b.Right)
+}
 
-	return ret
+func (ap AstPrinter) VisitConditionalExpr(c Conditional) string {
+	return ap.parenthesize("?:", c.Condition, c.Then, c.Else)
 }
 
-func (ap AstPrinter) visitBinaryExpr(b Expr) interface{} {
-	var ret string
-	if bb, ok := b.(Binary); ok {
-		ret = ap.parenthesize(bb.Operator.Lexeme, bb.Left, bb.Right)
-	}
+func (ap AstPrinter) VisitVariableExpr(v Variable) string {
+	return v.Name.Lexeme
+}
 
-	return ret
+func (ap AstPrinter) VisitCallExpr(c Call) string {
+	return ap.parenthesize("call", append([]Expr{c.Callee}, c.Arguments...)...)
 }
 
-func (ap AstPrinter) visitPrintStmt(p Stmt) interface{} {
-	if pp, ok := p.(Print); ok {
-		return ap.parenthesize("print", pp.Expression)
+func (ap AstPrinter) VisitInterpolationExpr(i Interpolation) string {
+	str := "(interpolate"
+	for n, part := range i.Parts {
+		if n%2 == 0 {
+			str += fmt.Sprintf(" %q", part.(Literal).Value)
+		} else {
+			str += " " + AcceptExpr[string](part, ap)
+		}
 	}
-	return nil
+
+	return str + ")"
 }
 
-func (ap AstPrinter) visitExpressionStmt(e Stmt) interface{} {
-	if ee, ok := e.(Expression); ok {
-		return ap.parenthesize(";", ee.Expression)
-	}
-	return nil
+func (ap AstPrinter) VisitPrintStmt(p Print) string {
+	return ap.parenthesize("print", p.Expression)
+}
+
+func (ap AstPrinter) VisitExpressionStmt(e Expression) string {
+	return ap.parenthesize(";", e.Expression)
 }
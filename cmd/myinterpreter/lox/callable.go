@@ -0,0 +1,53 @@
+package lox
+
+import (
+	"fmt"
+	"time"
+)
+
+// Callable is a value Lox code can call, the interpreter checks the number
+// of arguments against Arity before calling. Name appears in stack traces.
+type Callable interface {
+	Name() string
+	Arity() int
+	Call(interpreter *Interpreter, arguments []Value) (Value, error)
+}
+
+// NativeFunction is a function implemented in Go, an error it returns is
+// raised as a runtime error at the call. It can only be called if the
+// interpreter grants all its capabilities.
+type NativeFunction struct {
+	name         string
+	arity        int
+	fn           func(arguments []Value) (Value, error)
+	capabilities []Capability
+}
+
+func NewNativeFunction(name string, arity int, fn func(arguments []Value) (Value, error), capabilities ...Capability) *NativeFunction {
+	return &NativeFunction{name: name, arity: arity, fn: fn, capabilities: capabilities}
+}
+
+func (n *NativeFunction) Name() string {
+	return n.name
+}
+
+func (n *NativeFunction) Arity() int {
+	return n.arity
+}
+
+func (n *NativeFunction) Call(interpreter *Interpreter, arguments []Value) (Value, error) {
+	if c, ok := interpreter.granted(n.capabilities); !ok {
+		return nil, fmt.Errorf("Native function '%s' needs the %s capability.", n.name, c)
+	}
+
+	return n.fn(arguments)
+}
+
+func (n *NativeFunction) String() string {
+	return "<native fn>"
+}
+
+// clock returns the seconds elapsed since the Unix epoch
+func clock(arguments []Value) (Value, error) {
+	return float64(time.Now().UnixNano()) / float64(time.Second), nil
+}
@@ -0,0 +1,53 @@
+package lox
+
+import "fmt"
+
+// Capability is an access to the world outside of the program that a native
+// function needs the host to grant
+type Capability int
+
+const (
+	Filesystem Capability = iota
+	Network
+	Clock
+	Environment
+)
+
+var capabilityNames = []string{"filesystem", "network", "clock", "environment"}
+
+func (c Capability) String() string {
+	if int(c) < 0 || int(c) >= len(capabilityNames) {
+		return fmt.Sprintf("Capability(%d)", int(c))
+	}
+
+	return capabilityNames[c]
+}
+
+// WithCapabilities grants only the given capabilities, every one is granted
+// by default
+func WithCapabilities(capabilities ...Capability) InterpreterOption {
+	return func(i *Interpreter) {
+		i.capabilities = map[Capability]bool{}
+		for _, c := range capabilities {
+			i.capabilities[c] = true
+		}
+	}
+}
+
+// WithSandbox grants no capability: natives needing one cannot be called, so
+// the program can only compute from its source and what the host gives it
+func WithSandbox() InterpreterOption {
+	return WithCapabilities()
+}
+
+// granted returns false and the first capability of the list that is not
+// granted, if any
+func (i *Interpreter) granted(capabilities []Capability) (Capability, bool) {
+	for _, c := range capabilities {
+		if i.capabilities != nil && !i.capabilities[c] {
+			return c, false
+		}
+	}
+
+	return 0, true
+}
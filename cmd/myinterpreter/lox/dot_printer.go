@@ -0,0 +1,119 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+// DotPrinter renders the syntax tree as a Graphviz graph, each visit returns
+// the id of the graph node it added
+type DotPrinter struct {
+	out   strings.Builder
+	count int
+}
+
+func NewDotPrinter() *DotPrinter {
+	return &DotPrinter{}
+}
+
+func (dp *DotPrinter) graph(root func()) string {
+	dp.out.Reset()
+	dp.count = 0
+
+	dp.out.WriteString("digraph AST {\n  node [shape=box];\n")
+	root()
+	dp.out.WriteString("}\n")
+	return dp.out.String()
+}
+
+func (dp *DotPrinter) PrintExpr(expr Expr) string {
+	return dp.graph(func() {
+		AcceptExpr[string](expr, dp)
+	})
+}
+
+func (dp *DotPrinter) PrintStmts(statements []Stmt) string {
+	return dp.graph(func() {
+		root := dp.node("program")
+		for _, stmt := range statements {
+			dp.edge(root, AcceptStmt[string](stmt, dp))
+		}
+	})
+}
+
+func (dp *DotPrinter) node(label string) string {
+	id := fmt.Sprintf("n%d", dp.count)
+	dp.count += 1
+
+	fmt.Fprintf(&dp.out, "  %s [label=%s];\n", id, dotQuote(label))
+	return id
+}
+
+// dotQuote makes s a DOT quoted string, in which only quotes and backslashes
+// are escaped; other characters, newlines included, stand for themselves
+func dotQuote(s string) string {
+	return "\"" + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + "\""
+}
+
+func (dp *DotPrinter) edge(from string, to string) {
+	fmt.Fprintf(&dp.out, "  %s -> %s;\n", from, to)
+}
+
+// adds a node with an edge to each child, in order
+func (dp *DotPrinter) tree(label string, children ...Expr) string {
+	id := dp.node(label)
+	for _, child := range children {
+		dp.edge(id, AcceptExpr[string](child, dp))
+	}
+
+	return id
+}
+
+func (dp *DotPrinter) VisitLiteralExpr(l Literal) string {
+	switch value := l.Value.(type) {
+	case nil:
+		return dp.node("nil")
+	case string:
+		return dp.node("\"" + value + "\"")
+	default:
+		return dp.node(fmt.Sprintf("%v", scanner.HandleNumberLiteral(value)))
+	}
+}
+
+func (dp *DotPrinter) VisitGroupingExpr(g Grouping) string {
+	return dp.tree("group", g.Expression)
+}
+
+func (dp *DotPrinter) VisitUnaryExpr(u Unary) string {
+	return dp.tree(u.Operator.Lexeme, u.Right)
+}
+
+func (dp *DotPrinter) VisitBinaryExpr(b Binary) string {
+	return dp.tree(b.Operator.Lexeme, b.Left, b.Right)
+}
+
+func (dp *DotPrinter) VisitConditionalExpr(c Conditional) string {
+	return dp.tree("?:", c.Condition, c.Then, c.Else)
+}
+
+func (dp *DotPrinter) VisitVariableExpr(v Variable) string {
+	return dp.node(v.Name.Lexeme)
+}
+
+func (dp *DotPrinter) VisitCallExpr(c Call) string {
+	return dp.tree("call", append([]Expr{c.Callee}, c.Arguments...)...)
+}
+
+func (dp *DotPrinter) VisitInterpolationExpr(i Interpolation) string {
+	return dp.tree("interpolate", i.Parts...)
+}
+
+func (dp *DotPrinter) VisitPrintStmt(p Print) string {
+	return dp.tree("print", p.Expression)
+}
+
+func (dp *DotPrinter) VisitExpressionStmt(e Expression) string {
+	return dp.tree(";", e.Expression)
+}
@@ -0,0 +1,34 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+func TestDotPrinter(t *testing.T) {
+	expr := NewBinary(
+		NewUnary(scanner.NewToken(scanner.MINUS, "-", "null"), NewLiteral(1.0)),
+		scanner.NewToken(scanner.PLUS, "+", "null"),
+		NewLiteral("é\\\""),
+	)
+
+	want := `digraph AST {
+  node [shape=box];
+  n0 [label="program"];
+  n1 [label="print"];
+  n2 [label="+"];
+  n3 [label="-"];
+  n4 [label="1.0"];
+  n3 -> n4;
+  n2 -> n3;
+  n5 [label="\"é\\\"\""];
+  n2 -> n5;
+  n1 -> n2;
+  n0 -> n1;
+}
+`
+	if got := NewDotPrinter().PrintStmts([]Stmt{NewPrint(expr)}); got != want {
+		t.Errorf("PrintStmts() = %q, want %q", got, want)
+	}
+}
@@ -6,47 +6,65 @@ import (
 
 /** grammar rules
 expression     → literal
+               | variable
+               | call
+               | interpolation
                | unary
                | binary
+               | conditional
                | grouping ;
 
 literal        → NUMBER | STRING | "true" | "false" | "nil" ;
+interpolation  → '"...${' expression ( '}...${' expression )* '}..."' ;
+variable       → IDENTIFIER ;
+call           → expression "(" ( expression ( "," expression )* )? ")" ;
 grouping       → "(" expression ")" ;
 unary          → ( "-" | "!" ) expression ;
 binary         → expression operator expression ;
+conditional    → expression "?" expression ":" expression ;
 operator       → "==" | "!=" | "<" | "<=" | ">" | ">="
-               | "+"  | "-"  | "*" | "/" ;
+               | "+"  | "-"  | "*" | "/" | "," ;
 
 
 ** This still grammar has ambiguity
 **/
 
+// Expr is implemented by the expression nodes of this package, visit them
+// with AcceptExpr. Span is the source range of the whole expression, it is
+// only known for nodes built by the parser.
 type Expr interface {
-	Accept(v Visitor) interface{}
+	Span() scanner.Span
+	exprNode()
 }
 
 type Literal struct {
 	Value interface{}
+	span  scanner.Span
 }
 
 func NewLiteral(value interface{}) Literal {
 	return Literal{Value: value}
 }
 
-func (l Literal) Accept(v Visitor) interface{} {
-	return v.visitLiteralExpr(l)
+func (l Literal) exprNode() {}
+
+func (l Literal) Span() scanner.Span {
+	return l.span
 }
 
 type Grouping struct {
 	Expression Expr
+	span       scanner.Span
 }
 
 func NewGrouping(expression Expr) Grouping {
 	return Grouping{Expression: expression}
 }
 
-func (g Grouping) Accept(v Visitor) interface{} {
-	return v.visitGroupingExpr(g)
+func (g Grouping) exprNode() {}
+
+func (g Grouping) Span() scanner.Span {
+	return g.span
 }
 
 type Unary struct {
@@ -58,8 +76,10 @@ func NewUnary(operator scanner.Token, right Expr) Unary {
 	return Unary{Operator: operator, Right: right}
 }
 
-func (u Unary) Accept(v Visitor) interface{} {
-	return v.visitUnaryExpr(u)
+func (u Unary) exprNode() {}
+
+func (u Unary) Span() scanner.Span {
+	return scanner.Span{Start: u.Operator.Start(), End: u.Right.Span().End}
 }
 
 type Binary struct {
@@ -72,6 +92,77 @@ func NewBinary(left Expr, operator scanner.Token, right Expr) Binary {
 	return Binary{Left: left, Operator: operator, Right: right}
 }
 
-func (b Binary) Accept(v Visitor) interface{} {
-	return v.visitBinaryExpr(b)
+func (b Binary) exprNode() {}
+
+func (b Binary) Span() scanner.Span {
+	return scanner.Span{Start: b.Left.Span().Start, End: b.Right.Span().End}
+}
+
+// Variable reads the global named by Name
+type Variable struct {
+	Name scanner.Token
+}
+
+func NewVariable(name scanner.Token) Variable {
+	return Variable{Name: name}
+}
+
+func (v Variable) exprNode() {}
+
+func (v Variable) Span() scanner.Span {
+	return scanner.TokenSpan(v.Name, v.Name)
+}
+
+// Call applies the callee to the arguments, Paren is the closing parenthesis
+// runtime errors of the call are reported at
+type Call struct {
+	Callee    Expr
+	Paren     scanner.Token
+	Arguments []Expr
+}
+
+func NewCall(callee Expr, paren scanner.Token, arguments []Expr) Call {
+	return Call{Callee: callee, Paren: paren, Arguments: arguments}
+}
+
+func (c Call) exprNode() {}
+
+func (c Call) Span() scanner.Span {
+	return scanner.Span{Start: c.Callee.Span().Start, End: c.Paren.End()}
+}
+
+// Conditional is the ternary cond ? then : else
+type Conditional struct {
+	Condition Expr
+	Then      Expr
+	Else      Expr
+}
+
+func NewConditional(condition Expr, then Expr, els Expr) Conditional {
+	return Conditional{Condition: condition, Then: then, Else: els}
+}
+
+func (c Conditional) exprNode() {}
+
+func (c Conditional) Span() scanner.Span {
+	return scanner.Span{Start: c.Condition.Span().Start, End: c.Else.Span().End}
+}
+
+// Interpolation is a string with embedded expressions, Parts alternates
+// between the string segments (as literals) and the expressions, starting
+// and ending with a segment
+type Interpolation struct {
+	Parts []Expr
+}
+
+func NewInterpolation(parts []Expr) Interpolation {
+	return Interpolation{Parts: parts}
+}
+
+func (i Interpolation) exprNode() {}
+
+// the segments span from the quotes to the braces, so the parts cover the
+// whole string
+func (i Interpolation) Span() scanner.Span {
+	return scanner.Span{Start: i.Parts[0].Span().Start, End: i.Parts[len(i.Parts)-1].Span().End}
 }
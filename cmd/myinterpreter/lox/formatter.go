@@ -0,0 +1,302 @@
+package lox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+// Formatter prints nodes back as canonical Lox source. Expressions longer than
+// the width are broken after binary operators, a width of 0 never breaks.
+type Formatter struct {
+	width  int
+	column int // column the node being visited starts at
+	indent int // indentation of continuation lines
+}
+
+func NewFormatter(width int) *Formatter {
+	return &Formatter{width: width}
+}
+
+func (f *Formatter) FormatExpr(expr Expr) string {
+	f.column, f.indent = 0, 4
+	return f.format(expr)
+}
+
+func (f *Formatter) FormatStmt(stmt Stmt) string {
+	return AcceptStmt[string](stmt, f)
+}
+
+func (f *Formatter) format(expr Expr) string {
+	return AcceptExpr[string](expr, f)
+}
+
+func (f *Formatter) fits(s string) bool {
+	return f.width <= 0 || f.column+len(s) <= f.width
+}
+
+func (f *Formatter) flat(expr Expr) string {
+	return AcceptExpr[string](expr, &Formatter{})
+}
+
+// advance moves the column past the last line of s
+func (f *Formatter) advance(s string) {
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		f.column = len(s) - i - 1
+	} else {
+		f.column += len(s)
+	}
+}
+
+func (f *Formatter) VisitLiteralExpr(l Literal) string {
+	switch value := l.Value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "\"" + value + "\""
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func (f *Formatter) VisitGroupingExpr(g Grouping) string {
+	f.column += 1
+	return "(" + f.format(g.Expression) + ")"
+}
+
+func (f *Formatter) VisitUnaryExpr(u Unary) string {
+	f.column += len(u.Operator.Lexeme)
+	return u.Operator.Lexeme + f.format(u.Right)
+}
+
+// a chain of binary operators is filled greedily, breaking after the last
+// operator that still fits on the line
+func (f *Formatter) VisitBinaryExpr(b Binary) string {
+	if f.width <= 0 {
+		return f.format(b.Left) + infix(b.Operator.Lexeme) + " " + f.format(b.Right)
+	}
+
+	if flat := f.flat(b); f.fits(flat) {
+		return flat
+	}
+
+	// left-nested binaries print as one sequence of operands
+	operators := []string{}
+	operands := []Expr{}
+	var e Expr = b
+	for {
+		chained, ok := e.(Binary)
+		if !ok {
+			break
+		}
+
+		operators = append([]string{chained.Operator.Lexeme}, operators...)
+		operands = append([]Expr{chained.Right}, operands...)
+		e = chained.Left
+	}
+
+	str := f.format(e)
+	f.advance(str)
+	for n, operand := range operands {
+		piece := infix(operators[n]) + " " + f.flat(operand)
+		suffix := ""
+		if n+1 < len(operators) {
+			suffix = infix(operators[n+1])
+		}
+
+		if f.fits(piece + suffix) {
+			str += piece
+			f.column += len(piece)
+			continue
+		}
+
+		str += infix(operators[n]) + "\n" + strings.Repeat(" ", f.indent)
+		f.column = f.indent
+		formatted := f.format(operand)
+		str += formatted
+		f.advance(formatted)
+	}
+
+	return str
+}
+
+// infix is the operator as printed after the left operand, commas stick to it
+func infix(operator string) string {
+	if operator == "," {
+		return operator
+	}
+
+	return " " + operator
+}
+
+// a conditional is never broken itself, only its operands are
+func (f *Formatter) VisitConditionalExpr(c Conditional) string {
+	start := f.column
+	str := f.format(c.Condition) + " ? "
+	f.column = start
+	f.advance(str)
+
+	then := f.format(c.Then) + " : "
+	str += then
+	f.column = start
+	f.advance(str)
+
+	return str + f.format(c.Else)
+}
+
+func (f *Formatter) VisitVariableExpr(v Variable) string {
+	return v.Name.Lexeme
+}
+
+// the arguments of a call are never broken, only within themselves
+func (f *Formatter) VisitCallExpr(c Call) string {
+	start := f.column
+	str := f.format(c.Callee) + "("
+	for n, argument := range c.Arguments {
+		if n > 0 {
+			str += ", "
+		}
+
+		f.column = start
+		f.advance(str)
+		str += f.format(argument)
+	}
+
+	return str + ")"
+}
+
+// strings are never broken, not even around the interpolated expressions
+func (f *Formatter) VisitInterpolationExpr(i Interpolation) string {
+	str := "\""
+	for n, part := range i.Parts {
+		if n%2 == 0 {
+			str += part.(Literal).Value.(string)
+		} else {
+			str += "${" + f.flat(part) + "}"
+		}
+	}
+
+	return str + "\""
+}
+
+func (f *Formatter) VisitPrintStmt(p Print) string {
+	f.column, f.indent = len("print "), 4
+	return "print " + f.format(p.Expression) + ";"
+}
+
+func (f *Formatter) VisitExpressionStmt(e Expression) string {
+	f.column, f.indent = 0, 4
+	return f.format(e.Expression) + ";"
+}
+
+// comment is a // or /* */ comment found in the trivia of the tokens
+type comment struct {
+	text   string
+	offset int
+}
+
+func (c comment) end() int {
+	return c.offset + len(c.text)
+}
+
+func collectComments(tokens []scanner.Token) []comment {
+	comments := []comment{}
+	for _, t := range tokens {
+		comments = append(comments, triviaComments(t.LeadingTrivia, t.Offset-len(t.LeadingTrivia))...)
+		comments = append(comments, triviaComments(t.TrailingTrivia, t.Offset+len(t.Lexeme))...)
+	}
+
+	return comments
+}
+
+func triviaComments(trivia string, offset int) []comment {
+	comments := []comment{}
+	for i := 0; i < len(trivia); i++ {
+		end := -1
+		if strings.HasPrefix(trivia[i:], "//") {
+			end = strings.IndexByte(trivia[i:], '\n')
+			if end > 0 && trivia[i+end-1] == '\r' {
+				end -= 1
+			}
+		} else if strings.HasPrefix(trivia[i:], "/*") {
+			end = strings.Index(trivia[i+2:], "*/")
+			if end >= 0 {
+				end += 4
+			}
+		} else {
+			continue
+		}
+
+		if end < 0 {
+			end = len(trivia) - i
+		}
+
+		comments = append(comments, comment{text: trivia[i : i+end], offset: offset + i})
+		i += end - 1
+	}
+
+	return comments
+}
+
+// Format parses a whole program and prints it back canonically: one
+// statement per line, at most one blank line between statements, and
+// comments kept either on their own line or at the end of the line of the
+// statement they follow. Comments inside a statement are moved before it.
+// The options, like a dialect, are those the program is run with.
+func Format(source []byte, width int, opts ...scanner.Option) (string, error) {
+	sc := scanner.NewScanner(source, append(opts, scanner.WithTrivia())...)
+	sc.Tokenize()
+	if err := sc.Err(); err != nil {
+		return "", err
+	}
+
+	tokens := sc.GetTokens()
+	p := NewParser(tokens)
+	statements, err := p.Parse()
+	if err != nil {
+		return "", err
+	}
+
+	comments := collectComments(tokens)
+	f := NewFormatter(width)
+
+	var out strings.Builder
+	last := -1 // end offset of the last thing written, -1 at the start of the file
+	emitLine := func(text string, offset int, end int) {
+		if last >= 0 && last < offset && strings.Count(string(source[last:offset]), "\n") >= 2 {
+			out.WriteString("\n")
+		}
+
+		out.WriteString(text)
+		out.WriteString("\n")
+		last = end
+	}
+
+	for _, stmt := range statements {
+		span := stmt.Span()
+		for len(comments) > 0 && comments[0].offset < span.End.Offset {
+			emitLine(comments[0].text, comments[0].offset, comments[0].end())
+			comments = comments[1:]
+		}
+
+		line := f.FormatStmt(stmt)
+		end := span.End.Offset
+		for len(comments) > 0 && !strings.Contains(string(source[end:comments[0].offset]), "\n") {
+			line += " " + comments[0].text
+			end = comments[0].end()
+			comments = comments[1:]
+		}
+
+		emitLine(line, span.Start.Offset, end)
+	}
+
+	for _, c := range comments {
+		emitLine(c.text, c.offset, c.end())
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,69 @@
+package lox
+
+import (
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+func TestFormat(t *testing.T) {
+	source := `// header
+
+print   1+2 ; // trailing
+print "a ${ 1+2 } b"  ;
+
+
+/* block
+   comment */
+(1 + /* inside */ 2) * -3 == !true;
+print 111111111 + 222222222 + 333333333 + 444444444 + 555555555 + 666666666;
+1.50;2;
+print 1 ?2:3 , 4;
+// final`
+
+	want := `// header
+
+print 1 + 2; // trailing
+print "a ${1 + 2} b";
+
+/* block
+   comment */
+/* inside */
+(1 + 2) * -3 == !true;
+print 111111111 + 222222222 +
+    333333333 + 444444444 + 555555555 +
+    666666666;
+1.5;
+2;
+print 1 ? 2 : 3, 4;
+// final
+`
+
+	blockComments := scanner.WithDialect(scanner.NewDialect().AddBlockComments())
+	got, err := Format([]byte(source), 40, blockComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != want {
+		t.Errorf("Format() = %q, want %q", got, want)
+	}
+
+	again, err := Format([]byte(got), 40, blockComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != got {
+		t.Errorf("Format() is not idempotent, second run = %q", again)
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	// rejected like run does, standard Lox has no block comments
+	for _, source := range []string{"print 1", "print 1 /* c */;"} {
+		if _, err := Format([]byte(source), 80); err == nil {
+			t.Errorf("Format(%q) error = nil, want error", source)
+		}
+	}
+}
@@ -1,46 +1,320 @@
 package lox
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
 )
 
-type Interpreter struct{}
+// Value is anything a Lox expression evaluates to: nil, bool, float64, string
+// or a Callable
+type Value = interface{}
 
-func NewInterpreter() Interpreter {
-	return Interpreter{}
+type Interpreter struct {
+	output       io.Writer // where print writes
+	diagnostics  io.Writer // where runtime errors are reported
+	globals      map[string]Value
+	ctx          context.Context
+	stepLimit    int // 0 is unlimited
+	steps        int // taken by the outermost Run or Call
+	maxDepth     int
+	depth        int // nesting of the calls in progress
+	memoryLimit  int // in bytes, 0 is unlimited
+	allocated    int // by the outermost Run or Call
+	fileName     string
+	frames       []util.Frame        // calls in progress, innermost last
+	capabilities map[Capability]bool // granted to natives, nil grants all
+	entries      int                 // Run and Call in progress, nested when natives call back
 }
 
-func (i Interpreter) Interpret(statements []Stmt) {
-	for _, stmt := range statements {
-		i.Evaluate(stmt)
+// entry is where a Run or Call started, to return there once it is over
+type entry struct {
+	depth  int
+	frames int
+}
+
+// defaultMaxDepth stops deep recursion well before the Go stack overflows,
+// which would kill the process without a chance to recover
+const defaultMaxDepth = 10000
+
+type InterpreterOption func(*Interpreter)
+
+// WithOutput sets where the program prints, os.Stdout by default
+func WithOutput(w io.Writer) InterpreterOption {
+	return func(i *Interpreter) {
+		i.output = w
 	}
 }
 
-func (i Interpreter) Evaluate(expr Expr) interface{} {
-	return expr.Accept(i)
+// WithDiagnostics sets where runtime errors are reported, os.Stderr by default
+func WithDiagnostics(w io.Writer) InterpreterOption {
+	return func(i *Interpreter) {
+		i.diagnostics = w
+	}
 }
 
-func (i Interpreter) visitLiteralExpr(l Expr) interface{} {
-	if ll, ok := l.(Literal); ok {
-		return ll.Value
+// WithContext stops the program once the context is done
+func WithContext(ctx context.Context) InterpreterOption {
+	return func(i *Interpreter) {
+		i.ctx = ctx
 	}
+}
 
-	return nil
+// WithStepLimit stops each Run or Call after n steps, a step being a
+// statement or a call
+func WithStepLimit(n int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.stepLimit = n
+	}
 }
 
-func (i Interpreter) visitGroupingExpr(g Expr) interface{} {
-	if gg, ok := g.(Grouping); ok {
-		return i.Evaluate(gg.Expression)
+// WithMaxDepth sets how deep calls can nest before the program stops with a
+// stack overflow. 0 keeps the default depth rather than lifting the limit,
+// since unbounded recursion would crash the process.
+func WithMaxDepth(n int) InterpreterOption {
+	return func(i *Interpreter) {
+		if n <= 0 {
+			n = defaultMaxDepth
+		}
+		i.maxDepth = n
 	}
+}
 
-	return nil
+// WithMemoryLimit stops each Run or Call once it allocated more than n bytes
+// for the values it creates, like the strings it concatenates
+func WithMemoryLimit(n int) InterpreterOption {
+	return func(i *Interpreter) {
+		i.memoryLimit = n
+	}
+}
+
+// WithFileName names the file the program comes from in stack traces
+func WithFileName(name string) InterpreterOption {
+	return func(i *Interpreter) {
+		i.fileName = name
+	}
+}
+
+func NewInterpreter(opts ...InterpreterOption) *Interpreter {
+	i := &Interpreter{
+		output:      os.Stdout,
+		diagnostics: os.Stderr,
+		globals:     map[string]Value{},
+		ctx:         context.Background(),
+		maxDepth:    defaultMaxDepth,
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	i.DefineNative("clock", 0, clock, Clock)
+	return i
+}
+
+// DefineNative defines a global function implemented in Go, replacing any
+// global of the same name. Calling it needs the given capabilities.
+func (i *Interpreter) DefineNative(name string, arity int, fn func(arguments []Value) (Value, error), capabilities ...Capability) {
+	i.globals[name] = NewNativeFunction(name, arity, fn, capabilities...)
+}
+
+// SetContext replaces the context given by WithContext
+func (i *Interpreter) SetContext(ctx context.Context) {
+	i.ctx = ctx
+}
+
+// Context is the context the interpreter stops on
+func (i *Interpreter) Context() context.Context {
+	return i.ctx
+}
+
+// step counts one step against the budget of the program, at token
+func (i *Interpreter) step(token scanner.Token) {
+	i.steps++
+	if i.stepLimit > 0 && i.steps > i.stepLimit {
+		panic(util.NewBudgetError(token, nil))
+	}
+
+	if err := i.ctx.Err(); err != nil {
+		panic(util.NewBudgetError(token, err))
+	}
+}
+
+// begin starts a Run or Call. The outermost one starts with a fresh budget,
+// one started by a native calling back into the interpreter counts against
+// the budget of the run it is part of.
+func (i *Interpreter) begin() entry {
+	if i.entries == 0 {
+		i.steps, i.depth, i.frames, i.allocated = 0, 0, nil, 0
+	}
+
+	i.entries++
+	return entry{depth: i.depth, frames: len(i.frames)}
+}
+
+// end goes back to where the Run or Call started, a runtime error skipped
+// the leave calls and the popping of frames
+func (i *Interpreter) end(e entry) {
+	i.entries--
+	i.depth = e.depth
+	i.frames = i.frames[:e.frames]
+}
+
+// allocate accounts for n bytes about to be allocated at token
+func (i *Interpreter) allocate(token scanner.Token, n int) {
+	i.allocated += n
+	if i.memoryLimit > 0 && i.allocated > i.memoryLimit {
+		panic(util.NewOutOfMemoryError(token))
+	}
+}
+
+// enter goes one level deeper at token, leave goes back up. A runtime error
+// skips the leave calls, end restores the depth instead.
+func (i *Interpreter) enter(token scanner.Token) {
+	i.depth++
+	if i.depth > i.maxDepth {
+		panic(util.NewRuntimeError(token, "Stack overflow."))
+	}
+}
+
+func (i *Interpreter) leave() {
+	i.depth--
+}
+
+// Global returns the value of a global variable
+func (i *Interpreter) Global(name string) (Value, bool) {
+	value, ok := i.globals[name]
+	return value, ok
+}
+
+// DefineGlobal defines a global variable, replacing any previous one
+func (i *Interpreter) DefineGlobal(name string, value Value) {
+	i.globals[name] = value
+}
+
+// recoverRuntimeError is deferred by the entry points of the interpreter: a
+// runtime error gets the trace of the calls it happened in and is returned
+// in err. The outermost entry point also reports it to the diagnostics
+// writer.
+func (i *Interpreter) recoverRuntimeError(err *error) {
+	if r := recover(); r != nil {
+		runtimeErr, ok := r.(util.RuntimeError)
+		if !ok {
+			panic(r)
+		}
+
+		if len(i.frames) > 0 && len(runtimeErr.Trace()) == 0 {
+			trace := []util.Frame{}
+			for n := len(i.frames) - 1; n >= 0; n-- {
+				trace = append(trace, i.frames[n])
+			}
+
+			runtimeErr = runtimeErr.WithTrace(trace)
+		}
+
+		if i.entries == 1 {
+			fmt.Fprintf(i.diagnostics, "%v\n", runtimeErr.Error())
+		}
+		*err = runtimeErr
+	}
+}
+
+// Interpret runs the statements until the end or the first runtime error,
+// which is reported to the diagnostics writer and returned
+func (i *Interpreter) Interpret(statements []Stmt) error {
+	_, err := i.Run(statements)
+	return err
+}
+
+// Run is Interpret also returning the value of the last statement, which is
+// nil unless it is an expression statement
+func (i *Interpreter) Run(statements []Stmt) (value Value, err error) {
+	defer i.end(i.begin())
+	defer i.recoverRuntimeError(&err)
+
+	for _, stmt := range statements {
+		value = i.execute(stmt)
+	}
+
+	return value, nil
+}
+
+// callSite is where a Call from Go happens: the call of the native calling
+// back into the interpreter, or nowhere in the source for the host itself
+func (i *Interpreter) callSite() scanner.Token {
+	if n := len(i.frames); n > 0 {
+		return scanner.Token{Line: i.frames[n-1].Line}
+	}
+
+	return scanner.Token{}
+}
+
+// Call calls a Lox callable from Go, with the same checks as a call in Lox.
+// Its errors are runtime errors, handled like those of Run.
+func (i *Interpreter) Call(callee Value, arguments []Value) (value Value, err error) {
+	defer i.end(i.begin())
+	defer i.recoverRuntimeError(&err)
+
+	token := i.callSite()
+	i.step(token)
+	function, ok := callee.(Callable)
+	if !ok {
+		panic(
+			util.NewRuntimeError(token, "Can only call functions and classes."),
+		)
+	}
+
+	if len(arguments) != function.Arity() {
+		panic(
+			util.NewRuntimeError(token, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))),
+		)
+	}
+
+	i.enter(token)
+	i.frames = append(i.frames, util.Frame{Function: function.Name(), File: i.fileName, Line: token.Line})
+	value, err = function.Call(i, arguments)
+	if err != nil {
+		var runtimeErr util.RuntimeError
+		if errors.As(err, &runtimeErr) {
+			panic(runtimeErr)
+		}
+
+		panic(
+			util.NewRuntimeError(token, err.Error()),
+		)
+	}
+
+	i.frames = i.frames[:len(i.frames)-1]
+	i.leave()
+	return value, nil
+}
+
+func (i *Interpreter) execute(stmt Stmt) Value {
+	i.step(scanner.Token{Line: stmt.Span().Start.Line})
+	return AcceptStmt[Value](stmt, i)
+}
+
+func (i *Interpreter) Evaluate(expr Expr) Value {
+	return AcceptExpr[Value](expr, i)
+}
+
+func (i *Interpreter) VisitLiteralExpr(l Literal) Value {
+	return l.Value
+}
+
+func (i *Interpreter) VisitGroupingExpr(g Grouping) Value {
+	return i.Evaluate(g.Expression)
 }
 
 // Ruby’s simple rule: false and nil are falsey, and everything else is truthy
-func isTruthy(obj interface{}) bool {
+func isTruthy(obj Value) bool {
 	if obj == nil {
 		return false
 	}
@@ -52,7 +326,7 @@ func isTruthy(obj interface{}) bool {
 	return true
 }
 
-func checkNumberOperand(operator scanner.Token, operand interface{}) {
+func checkNumberOperand(operator scanner.Token, operand Value) {
 	if _, ok := operand.(float64); !ok {
 		panic(
 			util.NewRuntimeError(operator, "Operand must be a number."),
@@ -60,23 +334,21 @@ func checkNumberOperand(operator scanner.Token, operand interface{}) {
 	}
 }
 
-func (i Interpreter) visitUnaryExpr(u Expr) interface{} {
-	if uu, ok := u.(Unary); ok {
-		right := i.Evaluate(uu.Right)
+func (i *Interpreter) VisitUnaryExpr(u Unary) Value {
+	right := i.Evaluate(u.Right)
 
-		switch uu.Operator.TokenType {
-		case scanner.MINUS:
-			checkNumberOperand(uu.Operator, right)
-			return -(right.(float64))
-		case scanner.BANG:
-			return !isTruthy(right)
-		}
+	switch u.Operator.TokenType {
+	case scanner.MINUS:
+		checkNumberOperand(u.Operator, right)
+		return -(right.(float64))
+	case scanner.BANG:
+		return !isTruthy(right)
 	}
 
 	return nil
 }
 
-func isEqual(a, b interface{}) bool {
+func isEqual(a, b Value) bool {
 	if a == nil && b == nil {
 		return true
 	}
@@ -88,7 +360,7 @@ func isEqual(a, b interface{}) bool {
 	return a == b
 }
 
-func checkNumberOperands(operator scanner.Token, left, right interface{}) {
+func checkNumberOperands(operator scanner.Token, left, right Value) {
 	if _, ok := left.(float64); !ok {
 		panic(
 			util.NewRuntimeError(operator, "Left operand must be a number."),
@@ -102,70 +374,166 @@ func checkNumberOperands(operator scanner.Token, left, right interface{}) {
 	}
 }
 
-func (i Interpreter) visitBinaryExpr(b Expr) interface{} {
-	if bb, ok := b.(Binary); ok {
-		left := i.Evaluate(bb.Left)
-		right := i.Evaluate(bb.Right)
-
-		switch bb.Operator.TokenType {
-		case scanner.MINUS:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) - right.(float64)
-		case scanner.STAR:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) * right.(float64)
-		case scanner.SLASH:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) / right.(float64)
-		case scanner.GREATER:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) > right.(float64)
-		case scanner.GREATER_EQUAL:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) >= right.(float64)
-		case scanner.LESS:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) < right.(float64)
-		case scanner.LESS_EQUAL:
-			checkNumberOperands(bb.Operator, left, right)
-			return left.(float64) <= right.(float64)
-		case scanner.EQUAL_EQUAL:
-			return isEqual(left, right)
-		case scanner.BANG_EQUAL:
-			return !isEqual(left, right)
-		case scanner.PLUS:
-			if l, ok := left.(float64); ok {
-				if r, ok := right.(float64); ok {
-					return l + r
-				}
-			}
+func (i *Interpreter) VisitBinaryExpr(b Binary) Value {
+	left := i.Evaluate(b.Left)
+	right := i.Evaluate(b.Right)
 
-			if l, ok := left.(string); ok {
-				if r, ok := right.(string); ok {
-					return l + r
-				}
+	switch b.Operator.TokenType {
+	case scanner.MINUS:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) - right.(float64)
+	case scanner.STAR:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) * right.(float64)
+	case scanner.SLASH:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) / right.(float64)
+	case scanner.GREATER:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) > right.(float64)
+	case scanner.GREATER_EQUAL:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) >= right.(float64)
+	case scanner.LESS:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) < right.(float64)
+	case scanner.LESS_EQUAL:
+		checkNumberOperands(b.Operator, left, right)
+		return left.(float64) <= right.(float64)
+	case scanner.COMMA:
+		return right
+	case scanner.EQUAL_EQUAL:
+		return isEqual(left, right)
+	case scanner.BANG_EQUAL:
+		return !isEqual(left, right)
+	case scanner.PLUS:
+		if l, ok := left.(float64); ok {
+			if r, ok := right.(float64); ok {
+				return l + r
 			}
+		}
 
-			panic(
-				util.NewRuntimeError(bb.Operator, "Operands must be two numbers or two strings."),
-			)
+		if l, ok := left.(string); ok {
+			if r, ok := right.(string); ok {
+				i.allocate(b.Operator, len(l)+len(r))
+				return l + r
+			}
 		}
+
+		panic(
+			util.NewRuntimeError(b.Operator, "Operands must be two numbers or two strings."),
+		)
 	}
 
 	return nil
 }
 
-func (i Interpreter) visitExpressionStmt(es Stmt) interface{} {
-	if e, ok := es.(Expression); ok {
-		return i.Evaluate(e.Expression)
+// only the branch selected by the condition is evaluated
+func (i *Interpreter) VisitConditionalExpr(c Conditional) Value {
+	if isTruthy(i.Evaluate(c.Condition)) {
+		return i.Evaluate(c.Then)
 	}
-	return nil
+
+	return i.Evaluate(c.Else)
 }
 
-func (i Interpreter) visitPrintStmt(ps Stmt) interface{} {
-	if p, ok := ps.(Print); ok {
-		value := i.Evaluate(p.Expression)
-		fmt.Printf("%v\n", value)
+func (i *Interpreter) VisitVariableExpr(v Variable) Value {
+	value, ok := i.globals[v.Name.Lexeme]
+	if !ok {
+		panic(
+			util.NewRuntimeError(v.Name, fmt.Sprintf("Undefined variable '%s'.", v.Name.Lexeme)),
+		)
+	}
+
+	return value
+}
+
+func (i *Interpreter) VisitCallExpr(c Call) Value {
+	callee := i.Evaluate(c.Callee)
+
+	arguments := []Value{}
+	for _, argument := range c.Arguments {
+		arguments = append(arguments, i.Evaluate(argument))
+	}
+
+	function, ok := callee.(Callable)
+	if !ok {
+		panic(
+			util.NewRuntimeError(c.Paren, "Can only call functions and classes."),
+		)
 	}
+
+	if len(arguments) != function.Arity() {
+		panic(
+			util.NewRuntimeError(c.Paren, fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(arguments))),
+		)
+	}
+
+	i.step(c.Paren)
+	i.enter(c.Paren)
+	i.frames = append(i.frames, util.Frame{Function: function.Name(), File: i.fileName, Line: c.Paren.Line})
+	value, err := function.Call(i, arguments)
+	if err != nil {
+		// the error of a nested Run or Call stops the outer one as it is
+		var runtimeErr util.RuntimeError
+		if errors.As(err, &runtimeErr) {
+			panic(runtimeErr)
+		}
+
+		panic(
+			util.NewRuntimeError(c.Paren, err.Error()),
+		)
+	}
+
+	i.frames = i.frames[:len(i.frames)-1]
+	i.leave()
+	return value
+}
+
+func (i *Interpreter) VisitInterpolationExpr(in Interpolation) Value {
+	token := scanner.Token{Line: in.Span().Start.Line}
+	var str strings.Builder
+	for _, part := range in.Parts {
+		s := Stringify(i.Evaluate(part))
+		i.allocate(token, len(s))
+		str.WriteString(s)
+	}
+
+	return str.String()
+}
+
+// Stringify formats a value the way Lox shows it: numbers without exponent
+// nor trailing ".0", infinities as "Infinity" and "-Infinity", nil as "nil",
+// strings unquoted. Values that are not literals, like callables, describe
+// themselves with their String method.
+func Stringify(value Value) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		if math.IsInf(v, 1) {
+			return "Infinity"
+		} else if math.IsInf(v, -1) {
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+func (i *Interpreter) VisitExpressionStmt(e Expression) Value {
+	return i.Evaluate(e.Expression)
+}
+
+func (i *Interpreter) VisitPrintStmt(p Print) Value {
+	value := i.Evaluate(p.Expression)
+	fmt.Fprintln(i.output, Stringify(value))
 	return nil
 }
@@ -0,0 +1,332 @@
+package lox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
+)
+
+func parse(t *testing.T, source string) []Stmt {
+	t.Helper()
+
+	sc := scanner.NewScanner([]byte(source))
+	sc.Tokenize()
+	p := NewParser(sc.GetTokens())
+	statements, err := p.Parse()
+	if err != nil {
+		t.Fatalf("Parse(%q) error = %v", source, err)
+	}
+
+	return statements
+}
+
+func TestInterpretOutput(t *testing.T) {
+	var output, diagnostics bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&diagnostics))
+
+	err := interpreter.Interpret(parse(t, "print 1 + 2;\nprint \"a\" + \"b\";\nprint -\"c\";\nprint 4;"))
+	if err == nil {
+		t.Fatal("Interpret() error = nil, want runtime error")
+	}
+
+	if got, want := output.String(), "3\nab\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	if got, want := diagnostics.String(), "Operand must be a number.\n[line 3]\n"; got != want {
+		t.Errorf("diagnostics = %q, want %q", got, want)
+	}
+}
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		value Value
+		want  string
+	}{
+		{nil, "nil"},
+		{true, "true"},
+		{3.0, "3"},
+		{2.5, "2.5"},
+		{1e21, "1000000000000000000000"},
+		{"a b", "a b"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, test := range tests {
+		if got := Stringify(test.value); got != test.want {
+			t.Errorf("Stringify(%#v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+
+	var output bytes.Buffer
+	NewInterpreter(WithOutput(&output)).Interpret(parse(t, `print nil; print "${10 * 100} ${nil}";`))
+	if got, want := output.String(), "nil\n1000 nil\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestDefineNative(t *testing.T) {
+	var output, diagnostics bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&diagnostics))
+	interpreter.DefineNative("add", 2, func(arguments []Value) (Value, error) {
+		return arguments[0].(float64) + arguments[1].(float64), nil
+	})
+	interpreter.DefineNative("fail", 0, func(arguments []Value) (Value, error) {
+		return nil, errors.New("Host failure.")
+	})
+
+	if err := interpreter.Interpret(parse(t, `print add(1, 2) * 2; print add; print clock() > 0;`)); err != nil {
+		t.Fatalf("Interpret() error = %v", err)
+	}
+
+	if got, want := output.String(), "6\n<native fn>\ntrue\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"fail();", "Host failure.\n[line 1]\n  at fail (line 1)\n"},
+		{"add(1);", "Expected 2 arguments but got 1.\n[line 1]\n"},
+		{"\"add\"(1, 2);", "Can only call functions and classes.\n[line 1]\n"},
+		{"print sub(1, 2);", "Undefined variable 'sub'.\n[line 1]\n"},
+	}
+
+	for _, test := range tests {
+		diagnostics.Reset()
+		if err := interpreter.Interpret(parse(t, test.source)); err == nil {
+			t.Errorf("Interpret(%q) error = nil, want runtime error", test.source)
+		}
+
+		if got := diagnostics.String(); got != test.want {
+			t.Errorf("Interpret(%q) diagnostics = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestBudget(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&bytes.Buffer{}), WithStepLimit(3))
+
+	// each print is a step, the call of clock another
+	err := interpreter.Interpret(parse(t, "print 1;\nprint clock() > 0;\nprint 3;"))
+	var runtimeErr util.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind() != util.BudgetExceeded {
+		t.Fatalf("Interpret() error = %v, want budget exceeded", err)
+	}
+
+	if got, want := err.Error(), "Execution budget exceeded.\n[line 3]"; got != want {
+		t.Errorf("Interpret() error = %q, want %q", got, want)
+	}
+
+	if got, want := output.String(), "1\ntrue\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// the budget is per run
+	if err := interpreter.Interpret(parse(t, "1; 2; 3;")); err != nil {
+		t.Errorf("Interpret() error = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interpreter = NewInterpreter(WithOutput(&output), WithDiagnostics(&bytes.Buffer{}), WithContext(ctx))
+	interpreter.DefineNative("cancel", 0, func(arguments []Value) (Value, error) {
+		cancel()
+		return nil, nil
+	})
+
+	err = interpreter.Interpret(parse(t, "cancel();\nprint 4;"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Interpret() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&bytes.Buffer{}), WithMaxDepth(4))
+	interpreter.DefineNative("recurse", 1, func(arguments []Value) (Value, error) {
+		n := arguments[0].(float64)
+		if n == 0 {
+			return n, nil
+		}
+
+		recurse, _ := interpreter.Global("recurse")
+		return interpreter.Call(recurse, []Value{n - 1})
+	})
+
+	// the call in the source and three nested ones
+	if err := interpreter.Interpret(parse(t, "print recurse(3);")); err != nil {
+		t.Fatalf("Interpret() error = %v, want nil", err)
+	}
+
+	// each nested call is a frame of the trace, the one past the maximum
+	// depth never started
+	err := interpreter.Interpret(parse(t, "print recurse(3);\nprint recurse(4);"))
+	want := "Stack overflow.\n[line 2]" + strings.Repeat("\n  at recurse (line 2)", 4)
+	if err == nil || err.Error() != want {
+		t.Errorf("Interpret() error = %v, want %q", err, want)
+	}
+
+	// the depth is back to zero after the error, and nested expressions
+	// are not calls
+	if err := interpreter.Interpret(parse(t, "print recurse(recurse(recurse(----3)));")); err != nil {
+		t.Errorf("Interpret() error = %v, want nil", err)
+	}
+
+	if got, want := output.String(), "0\n0\n0\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	// 0 keeps the default depth
+	interpreter = NewInterpreter(WithOutput(&output), WithMaxDepth(0))
+	if err := interpreter.Interpret(parse(t, "clock();")); err != nil {
+		t.Errorf("Interpret() error = %v, want nil", err)
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	var diagnostics bytes.Buffer
+	interpreter := NewInterpreter(WithDiagnostics(&diagnostics), WithFileName("script.lox"))
+	interpreter.DefineNative("fail", 0, func(arguments []Value) (Value, error) {
+		return nil, errors.New("Host failure.")
+	})
+
+	err := interpreter.Interpret(parse(t, "print 1;\nfail();"))
+	want := "Host failure.\n[line 2]\n  at fail (script.lox:2)"
+	if err == nil || err.Error() != want {
+		t.Errorf("Interpret() error = %v, want %q", err, want)
+	}
+
+	if got := diagnostics.String(); got != want+"\n" {
+		t.Errorf("diagnostics = %q, want %q", got, want+"\n")
+	}
+
+	var runtimeErr util.RuntimeError
+	if !errors.As(err, &runtimeErr) || len(runtimeErr.Trace()) != 1 || runtimeErr.Trace()[0] != (util.Frame{Function: "fail", File: "script.lox", Line: 2}) {
+		t.Errorf("Trace() = %v", runtimeErr.Trace())
+	}
+
+	// no trace outside of calls
+	if err := interpreter.Interpret(parse(t, "-nil;")); err == nil || err.Error() != "Operand must be a number.\n[line 1]" {
+		t.Errorf("Interpret() error = %v", err)
+	}
+}
+
+func TestReentrantCall(t *testing.T) {
+	var diagnostics bytes.Buffer
+	interpreter := NewInterpreter(WithDiagnostics(&diagnostics), WithStepLimit(5))
+	interpreter.DefineNative("again", 1, func(arguments []Value) (Value, error) {
+		callee, _ := interpreter.Global("clock")
+		return interpreter.Call(callee, nil)
+	})
+
+	// the nested calls take steps from the budget of the run
+	if err := interpreter.Interpret(parse(t, "again(1);")); err != nil {
+		t.Fatalf("Interpret() error = %v, want nil", err)
+	}
+
+	err := interpreter.Interpret(parse(t, "again(1);\nagain(2);"))
+	var runtimeErr util.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind() != util.BudgetExceeded {
+		t.Fatalf("Interpret() error = %v, want budget exceeded", err)
+	}
+
+	// raised at the call of the native, with its frame, and reported once
+	want := "Execution budget exceeded.\n[line 2]\n  at again (line 2)"
+	if err.Error() != want {
+		t.Errorf("Interpret() error = %q, want %q", err.Error(), want)
+	}
+
+	if got := diagnostics.String(); got != want+"\n" {
+		t.Errorf("diagnostics = %q, want %q", got, want+"\n")
+	}
+
+	// a call from the host has no line
+	interpreter = NewInterpreter(WithDiagnostics(&bytes.Buffer{}))
+	if _, err := interpreter.Call(1.0, nil); err == nil || err.Error() != "Can only call functions and classes." {
+		t.Errorf("Call() error = %v, want not callable error", err)
+	}
+
+	interpreter.DefineNative("fail", 0, func(arguments []Value) (Value, error) {
+		return nil, errors.New("Host failure.")
+	})
+	fail, _ := interpreter.Global("fail")
+	if _, err := interpreter.Call(fail, nil); err == nil || err.Error() != "Host failure.\n  at fail" {
+		t.Errorf("Call() error = %v, want host failure", err)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&bytes.Buffer{}), WithMemoryLimit(12))
+
+	// "abc" + "def" allocates 6 bytes, the interpolation copies them again
+	if err := interpreter.Interpret(parse(t, `print "${"abc" + "def"}";`)); err != nil {
+		t.Fatalf("Interpret() error = %v, want nil", err)
+	}
+
+	err := interpreter.Interpret(parse(t, `print "abc" + "def";`+"\n"+`print "abcd" + "efg";`))
+	var runtimeErr util.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind() != util.OutOfMemory {
+		t.Fatalf("Interpret() error = %v, want out of memory", err)
+	}
+
+	if got, want := err.Error(), "Out of memory.\n[line 2]"; got != want {
+		t.Errorf("Interpret() error = %q, want %q", got, want)
+	}
+
+	if got, want := output.String(), "abcdef\nabcdef\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	var output bytes.Buffer
+	interpreter := NewInterpreter(WithOutput(&output), WithDiagnostics(&bytes.Buffer{}), WithCapabilities(Environment))
+	interpreter.DefineNative("home", 0, func(arguments []Value) (Value, error) {
+		return "/home/lox", nil
+	}, Environment)
+	interpreter.DefineNative("fetch", 1, func(arguments []Value) (Value, error) {
+		return "", nil
+	}, Environment, Network)
+
+	if err := interpreter.Interpret(parse(t, "print home();")); err != nil {
+		t.Fatalf("Interpret() error = %v, want nil", err)
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"clock();", "Native function 'clock' needs the clock capability.\n[line 1]\n  at clock (line 1)"},
+		{"fetch(\"a\");", "Native function 'fetch' needs the network capability.\n[line 1]\n  at fetch (line 1)"},
+	}
+
+	for _, test := range tests {
+		if err := interpreter.Interpret(parse(t, test.source)); err == nil || err.Error() != test.want {
+			t.Errorf("Interpret(%q) error = %v, want %q", test.source, err, test.want)
+		}
+	}
+
+	// natives without capabilities are always allowed
+	sandboxed := NewInterpreter(WithOutput(&output), WithSandbox())
+	sandboxed.DefineNative("twice", 1, func(arguments []Value) (Value, error) {
+		return arguments[0].(float64) * 2, nil
+	})
+	if err := sandboxed.Interpret(parse(t, "print twice(2);")); err != nil {
+		t.Errorf("Interpret() error = %v, want nil", err)
+	}
+
+	if got, want := output.String(), "/home/lox\n4\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
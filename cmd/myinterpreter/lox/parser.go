@@ -4,20 +4,30 @@ package lox
 Precedence is from lowest to highest
 
 Name        Operators    Associates
+Comma       ,            Left
+Conditional ?:           Right
 Equality    == !=        Left
 Comparison  > >= < <=    Left
 Term        - +          Left
 Factor      / *          Left
 Unary       ! -          Right
+Call        ()           Left
+
+Expressions are parsed by precedence climbing (a Pratt parser): the rules
+table maps each token type to its prefix and infix handlers and its binding
+power, so a new operator only needs a new entry there.
 
 Grammar:
-expression     → equality ;
-equality       → comparison ( ( "!=" | "==" ) comparison )* ;
-comparison     → term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
-term           → factor ( ( "-" | "+" ) factor )* ; // * means zero or more
-factor         → unary ( ( "/" | "*" ) unary )* ;
-unary          → ( "!" | "-" ) unary | primary ;
+expression     → conditional ( "," conditional )* ;
+conditional    → binary ( "?" expression ":" conditional )? ;
+binary         → unary ( binaryOp unary )* ; // resolved by the precedence table
+binaryOp       → "!=" | "==" | ">" | ">=" | "<" | "<=" | "-" | "+" | "/" | "*" ;
+unary          → ( "!" | "-" ) unary | call ;
+call           → primary ( "(" arguments? ")" )* ;
+arguments      → conditional ( "," conditional )* ;
 primary        → NUMBER | STRING | "true" | "false" | "nil" | "(" expression ")"
+               | IDENTIFIER | interpolation ;
+interpolation  → INTERPOLATION expression ( INTERPOLATION expression )* STRING ;
 
 // new rules for statements
 program        → statement* EOF ;
@@ -31,6 +41,7 @@ printStmt      → "print" expression ";" ;
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
@@ -39,6 +50,25 @@ import (
 type Parser struct {
 	tokens  []scanner.Token
 	current int
+	errors  []error // reported by error productions, parsing went on after them
+	depth   int     // nesting of the expressions being parsed
+}
+
+// ParseErrors is the list of every error found while parsing, each message
+// already ends with a newline
+type ParseErrors []error
+
+func (e ParseErrors) Error() string {
+	str := ""
+	for _, err := range e {
+		str += err.Error()
+	}
+
+	return str
+}
+
+func (e ParseErrors) Unwrap() []error {
+	return e
 }
 
 func NewParser(tokens []scanner.Token) Parser {
@@ -85,142 +115,289 @@ func (p *Parser) matchAny(types ...scanner.TokenType) bool {
 	return false
 }
 
-func (p *Parser) consume(t scanner.TokenType, expectedTokenMsg string) (scanner.Token, error) {
+// consume reports the message at the current token if it is not of type t
+func (p *Parser) consume(t scanner.TokenType, message string) (scanner.Token, error) {
 	if p.check(t) {
 		return p.advance(), nil
 	}
 
-	return scanner.Token{}, fmt.Errorf("expect '%s' after expression", expectedTokenMsg)
+	return scanner.Token{}, fmt.Errorf(util.Error(p.peek(), message))
 }
 
-func (p *Parser) expression() (Expr, error) {
-	return p.equality()
+// precedence is the binding power of an operator, from lowest to highest
+type precedence int
+
+const (
+	precNone precedence = iota
+	precComma
+	precConditional
+	precEquality
+	precComparison
+	precTerm
+	precFactor
+	precUnary
+	precCall
+)
+
+type associativity int
+
+const (
+	leftAssoc associativity = iota
+	rightAssoc
+)
+
+// prefixFn parses an expression starting at the current token
+type prefixFn func(p *Parser) (Expr, error)
+
+// infixFn parses the rest of an expression whose left operand is already
+// parsed; the operator token has already been consumed
+type infixFn func(p *Parser, left Expr) (Expr, error)
+
+type parseRule struct {
+	prefix     prefixFn
+	infix      infixFn
+	precedence precedence
 }
 
-func (p *Parser) equality() (Expr, error) {
-	expr, err := p.comparison()
-	if err != nil {
-		return NewLiteral(nil), err
+// rules is the precedence table driving the parser; it is filled in init as
+// the handlers refer back to it
+var rules map[scanner.TokenType]parseRule
+
+func init() {
+	minus := binary(precTerm, leftAssoc)
+	minus.prefix = (*Parser).unary
+
+	rules = map[scanner.TokenType]parseRule{
+		scanner.COMMA:         binary(precComma, leftAssoc),
+		scanner.QUESTION:      {infix: (*Parser).conditional, precedence: precConditional},
+		scanner.BANG_EQUAL:    binary(precEquality, leftAssoc),
+		scanner.EQUAL_EQUAL:   binary(precEquality, leftAssoc),
+		scanner.GREATER:       binary(precComparison, leftAssoc),
+		scanner.GREATER_EQUAL: binary(precComparison, leftAssoc),
+		scanner.LESS:          binary(precComparison, leftAssoc),
+		scanner.LESS_EQUAL:    binary(precComparison, leftAssoc),
+		scanner.MINUS:         minus,
+		scanner.PLUS:          binary(precTerm, leftAssoc),
+		scanner.SLASH:         binary(precFactor, leftAssoc),
+		scanner.STAR:          binary(precFactor, leftAssoc),
+		scanner.BANG:          {prefix: (*Parser).unary},
+		scanner.LEFT_PAREN:    {prefix: (*Parser).primary, infix: (*Parser).call, precedence: precCall},
 	}
+}
 
-	for p.matchAny(scanner.BANG_EQUAL, scanner.EQUAL_EQUAL) {
-		operator := p.previous()
-		right, err := p.comparison()
-		if err != nil {
-			return NewLiteral(nil), err
-		}
-
-		expr = NewBinary(expr, operator, right)
+// binary returns the rule of a binary operator; a left-associative operator
+// only takes operators binding tighter in its right operand
+func binary(prec precedence, assoc associativity) parseRule {
+	rightPrec := prec + 1
+	if assoc == rightAssoc {
+		rightPrec = prec
 	}
 
-	return expr, nil
+	return parseRule{
+		prefix: missingLeftOperand(rightPrec),
+		infix: func(p *Parser, left Expr) (Expr, error) {
+			operator := p.previous()
+			right, err := p.parsePrecedence(rightPrec)
+			if err != nil {
+				return NewLiteral(nil), err
+			}
+
+			return NewBinary(left, operator, right), nil
+		},
+		precedence: prec,
+	}
 }
 
-func (p *Parser) comparison() (Expr, error) {
-	expr, err := p.term()
-	if err != nil {
-		return NewLiteral(nil), err
+// missingLeftOperand is the error production of a binary operator starting
+// an expression: the error is reported and the right operand is parsed, it
+// stands in for the whole expression so that parsing can go on
+func missingLeftOperand(rightPrec precedence) prefixFn {
+	return func(p *Parser) (Expr, error) {
+		operator := p.advance()
+		p.errors = append(p.errors, fmt.Errorf(util.Error(operator, "Missing left-hand operand.")))
+		return p.parsePrecedence(rightPrec)
 	}
+}
 
-	for p.matchAny(scanner.GREATER, scanner.GREATER_EQUAL, scanner.LESS, scanner.LESS_EQUAL) {
-		operator := p.previous()
-		right, err := p.term()
-		if err != nil {
-			return NewLiteral(nil), err
-		}
+func (p *Parser) expression() (Expr, error) {
+	return p.parsePrecedence(precComma)
+}
 
-		expr = NewBinary(expr, operator, right)
+// maxNesting is how deep expressions can nest. The parser and every walk of
+// the tree recurse once per level, deeper source would overflow the Go stack
+// and kill the process.
+const maxNesting = 10000
+
+// parsePrecedence parses an expression made of operators binding at least as
+// tightly as prec
+func (p *Parser) parsePrecedence(prec precedence) (Expr, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxNesting {
+		return NewLiteral(nil), fmt.Errorf(util.Error(p.peek(), "Expression nested too deeply."))
 	}
 
-	return expr, nil
-}
+	prefix := rules[p.peek().TokenType].prefix
+	if prefix == nil {
+		prefix = (*Parser).primary
+	}
 
-func (p *Parser) term() (Expr, error) {
-	expr, err := p.factor()
+	expr, err := prefix(p)
 	if err != nil {
 		return NewLiteral(nil), err
 	}
 
-	for p.matchAny(scanner.MINUS, scanner.PLUS) {
-		operator := p.previous()
-		right, err := p.factor()
+	for {
+		rule := rules[p.peek().TokenType]
+		if rule.infix == nil || rule.precedence < prec {
+			return expr, nil
+		}
+
+		p.advance()
+		expr, err = rule.infix(p, expr)
 		if err != nil {
 			return NewLiteral(nil), err
 		}
+	}
+}
 
-		expr = NewBinary(expr, operator, right)
+// like in C the then branch can be any expression, and the else branch
+// binds to the right: a ? b : c ? d : e is a ? b : (c ? d : e)
+func (p *Parser) conditional(condition Expr) (Expr, error) {
+	then, err := p.expression()
+	if err != nil {
+		return NewLiteral(nil), err
 	}
 
-	return expr, nil
-}
+	if _, err := p.consume(scanner.COLON, "Expect ':' after then branch of conditional expression."); err != nil {
+		return NewLiteral(nil), err
+	}
 
-func (p *Parser) factor() (Expr, error) {
-	expr, err := p.unary()
+	els, err := p.parsePrecedence(precConditional)
 	if err != nil {
 		return NewLiteral(nil), err
 	}
 
-	for p.matchAny(scanner.SLASH, scanner.STAR) {
-		operator := p.previous()
-		right, err := p.unary()
-		if err != nil {
-			return NewLiteral(nil), err
+	return NewConditional(condition, then, els), nil
+}
+
+// maxArguments is the most arguments a call can take
+const maxArguments = 255
+
+// the arguments are conditionals as the commas separate them
+func (p *Parser) call(callee Expr) (Expr, error) {
+	arguments := []Expr{}
+	if !p.check(scanner.RIGHT_PAREN) {
+		for {
+			if len(arguments) == maxArguments {
+				p.errors = append(p.errors, fmt.Errorf(util.Error(p.peek(), fmt.Sprintf("Can't have more than %d arguments.", maxArguments))))
+			}
+
+			argument, err := p.parsePrecedence(precConditional)
+			if err != nil {
+				return NewLiteral(nil), err
+			}
+			arguments = append(arguments, argument)
+
+			if !p.matchAny(scanner.COMMA) {
+				break
+			}
 		}
+	}
 
-		expr = NewBinary(expr, operator, right)
+	paren, err := p.consume(scanner.RIGHT_PAREN, "Expect ')' after arguments.")
+	if err != nil {
+		return NewLiteral(nil), err
 	}
 
-	return expr, nil
+	return NewCall(callee, paren, arguments), nil
 }
 
 func (p *Parser) unary() (Expr, error) {
-	if p.matchAny(scanner.BANG, scanner.MINUS) {
-		operator := p.previous()
-		right, err := p.unary()
-		if err != nil {
-			return NewLiteral(nil), err
-		}
-
-		return NewUnary(operator, right), nil
+	operator := p.advance()
+	right, err := p.parsePrecedence(precUnary)
+	if err != nil {
+		return NewLiteral(nil), err
 	}
 
-	return p.primary()
+	return NewUnary(operator, right), nil
 }
 
 func (p *Parser) primary() (Expr, error) {
 	if p.matchAny(scanner.FALSE) {
-		return NewLiteral(false), nil
+		return literalAt(p.previous(), false), nil
 	}
 
 	if p.matchAny(scanner.TRUE) {
-		return NewLiteral(true), nil
+		return literalAt(p.previous(), true), nil
 	}
 
 	if p.matchAny(scanner.NIL) {
-		return NewLiteral(nil), nil
+		return literalAt(p.previous(), nil), nil
 	}
 
 	if p.matchAny(scanner.NUMBER, scanner.STRING) {
-		return NewLiteral(p.previous().Literal), nil
+		return literalAt(p.previous(), p.previous().Literal), nil
+	}
+
+	if p.matchAny(scanner.IDENTIFIER) {
+		return NewVariable(p.previous()), nil
+	}
+
+	if p.matchAny(scanner.INTERPOLATION) {
+		return p.interpolation()
 	}
 
 	if p.matchAny(scanner.LEFT_PAREN) {
+		paren := p.previous()
 		expr, err := p.expression()
 		if err != nil {
 			return NewLiteral(nil), err
 		}
 
-		_, err = p.consume(scanner.RIGHT_PAREN, ")")
+		closing, err := p.consume(scanner.RIGHT_PAREN, "Expect ')' after expression.")
 		if err != nil {
 			return NewLiteral(nil), err
 		}
 
-		return NewGrouping(expr), nil
+		grouping := NewGrouping(expr)
+		grouping.span = scanner.TokenSpan(paren, closing)
+		return grouping, nil
 	}
 
 	return NewLiteral(nil), fmt.Errorf(util.Error(p.peek(), "Expect expression"))
 }
 
+func literalAt(token scanner.Token, value interface{}) Literal {
+	literal := NewLiteral(value)
+	literal.span = scanner.TokenSpan(token, token)
+	return literal
+}
+
+// the INTERPOLATION token opening the string has already been consumed
+func (p *Parser) interpolation() (Expr, error) {
+	parts := []Expr{literalAt(p.previous(), p.previous().Literal)}
+
+	for {
+		expr, err := p.expression()
+		if err != nil {
+			return NewLiteral(nil), err
+		}
+		parts = append(parts, expr)
+
+		// the string continues after the closing brace of the expression
+		if (!p.check(scanner.INTERPOLATION) && !p.check(scanner.STRING)) || !strings.HasPrefix(p.peek().Lexeme, "}") {
+			return NewLiteral(nil), fmt.Errorf(util.Error(p.peek(), "Expect '}' after interpolated expression."))
+		}
+
+		segment := p.advance()
+		parts = append(parts, literalAt(segment, segment.Literal))
+		if segment.TokenType == scanner.STRING {
+			return NewInterpolation(parts), nil
+		}
+	}
+}
+
 func (p *Parser) statement() (Stmt, error) {
 	if p.matchAny(scanner.PRINT) {
 		return p.printStatement()
@@ -230,17 +407,20 @@ func (p *Parser) statement() (Stmt, error) {
 }
 
 func (p *Parser) printStatement() (Stmt, error) {
+	keyword := p.previous()
 	value, err := p.expression()
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = p.consume(scanner.SEMICOLON, "';'")
+	semicolon, err := p.consume(scanner.SEMICOLON, "Expect ';' after value.")
 	if err != nil {
 		return nil, err
 	}
 
-	return NewPrint(value), nil
+	print := NewPrint(value)
+	print.span = scanner.TokenSpan(keyword, semicolon)
+	return print, nil
 }
 
 func (p *Parser) expressionStatement() (Stmt, error) {
@@ -249,16 +429,38 @@ func (p *Parser) expressionStatement() (Stmt, error) {
 		return nil, err
 	}
 
-	_, err = p.consume(scanner.SEMICOLON, "';'")
+	semicolon, err := p.consume(scanner.SEMICOLON, "Expect ';' after expression.")
 	if err != nil {
 		return nil, err
 	}
 
-	return NewExpression(value), nil
+	expression := NewExpression(value)
+	expression.span = scanner.Span{Start: value.Span().Start, End: semicolon.End()}
+	return expression, nil
+}
+
+// failure returns the errors reported by error productions followed by err,
+// or nil if there are none
+func (p *Parser) failure(err error) error {
+	errs := p.errors
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return ParseErrors(errs)
 }
 
 func (p *Parser) ParseExpr() (Expr, error) {
-	return p.expression()
+	expr, err := p.expression()
+	if err = p.failure(err); err != nil {
+		return NewLiteral(nil), err
+	}
+
+	return expr, nil
 }
 
 func (p *Parser) Parse() ([]Stmt, error) {
@@ -266,11 +468,41 @@ func (p *Parser) Parse() ([]Stmt, error) {
 	for !p.isAtEnd() {
 		stmt, err := p.statement()
 		if err != nil {
-			return nil, err
+			return nil, p.failure(err)
 		}
 
 		statements = append(statements, stmt)
 	}
 
+	if err := p.failure(nil); err != nil {
+		return nil, err
+	}
+
 	return statements, nil
 }
+
+// ParseExprOrProgram parses the tokens as a single expression if they are
+// exactly one, and as a program otherwise. Exactly one of expr and statements
+// is set. If neither parse succeeds, the error of the one that got further is
+// returned, preferring the expression one.
+func ParseExprOrProgram(tokens []scanner.Token) (Expr, []Stmt, error) {
+	exprParser := NewParser(tokens)
+	expr, exprErr := exprParser.ParseExpr()
+	if exprErr == nil && exprParser.isAtEnd() {
+		return expr, nil, nil
+	}
+
+	programParser := NewParser(tokens)
+	statements, err := programParser.Parse()
+	if err == nil {
+		return nil, statements, nil
+	}
+
+	// an expression followed by more tokens is no valid expression either,
+	// source the expression parse got as far in is reported as one
+	if exprErr != nil && exprParser.current >= programParser.current {
+		return nil, nil, exprErr
+	}
+
+	return nil, nil, err
+}
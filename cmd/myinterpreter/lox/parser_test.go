@@ -1,6 +1,8 @@
 package lox
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
@@ -36,12 +38,237 @@ func TestExpression(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		if test.expr.Accept(NewAstPrinter()).(string) == "" {
-			t.Errorf("Expression() = %q, want non-empty string", test.expr.Accept(NewAstPrinter()).(string))
+		if AcceptExpr[string](test.expr, NewAstPrinter()) == "" {
+			t.Errorf("Expression() = %q, want non-empty string", AcceptExpr[string](test.expr, NewAstPrinter()))
 		}
 
-		if test.expr.Accept(NewAstPrinter()).(string) != test.want {
-			t.Errorf("Expression() = %q, want %q", test.expr.Accept(NewAstPrinter()).(string), test.want)
+		if AcceptExpr[string](test.expr, NewAstPrinter()) != test.want {
+			t.Errorf("Expression() = %q, want %q", AcceptExpr[string](test.expr, NewAstPrinter()), test.want)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			source: `"x = ${1 + 2}!"`,
+			want:   `(interpolate "x = " (+ 1.0 2.0) "!")`,
+		},
+		{
+			source: `"${"a${true}"}${nil}"`,
+			want:   `(interpolate "" (interpolate "a" true "") "" nil "")`,
+		},
+	}
+
+	for _, test := range tests {
+		sc := scanner.NewScanner([]byte(test.source))
+		sc.Tokenize()
+		p := NewParser(sc.GetTokens())
+		expr, err := p.ParseExpr()
+		if err != nil {
+			t.Fatalf("ParseExpr(%q) error = %v", test.source, err)
+		}
+
+		if got := NewAstPrinter().Print(expr); got != test.want {
+			t.Errorf("ParseExpr(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			source: "1 - 2 - 3",
+			want:   "(- (- 1.0 2.0) 3.0)",
+		},
+		{
+			source: "1 + 2 * 3 == 7",
+			want:   "(== (+ 1.0 (* 2.0 3.0)) 7.0)",
+		},
+		{
+			source: "-1 * !true < 2 / 4",
+			want:   "(< (* (- 1.0) (! true)) (/ 2.0 4.0))",
+		},
+		{
+			source: "--(1 > 2) != nil",
+			want:   "(!= (- (- (group (> 1.0 2.0)))) nil)",
+		},
+		{
+			source: "1 == 2 ? 3 : 4 ? 5 : 6",
+			want:   "(?: (== 1.0 2.0) 3.0 (?: 4.0 5.0 6.0))",
+		},
+		{
+			source: "1, 2 ? 3, 4 : 5, 6",
+			want:   "(, (, 1.0 (?: 2.0 (, 3.0 4.0) 5.0)) 6.0)",
+		},
+		{
+			source: "-f(1, a ? b : c)(g()) + 2",
+			want:   "(+ (- (call (call f 1.0 (?: a b c)) (call g))) 2.0)",
+		},
+	}
+
+	for _, test := range tests {
+		sc := scanner.NewScanner([]byte(test.source))
+		sc.Tokenize()
+		p := NewParser(sc.GetTokens())
+		expr, err := p.ParseExpr()
+		if err != nil {
+			t.Fatalf("ParseExpr(%q) error = %v", test.source, err)
+		}
+
+		if got := NewAstPrinter().Print(expr); got != test.want {
+			t.Errorf("ParseExpr(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{
+			source: "print true ? 1;",
+			want:   "[line 1] Error at ';': Expect ':' after then branch of conditional expression.\n",
+		},
+		{
+			source: "(1 + 2",
+			want:   "[line 1] Error at end: Expect ')' after expression.\n",
+		},
+		{
+			source: "* 3 == 2;\nprint (== 2) + 1;",
+			want:   "[line 1] Error at '*': Missing left-hand operand.\n[line 2] Error at '==': Missing left-hand operand.\n",
+		},
+		{
+			source: "print , 1;\nprint 1 +;",
+			want:   "[line 1] Error at ',': Missing left-hand operand.\n[line 2] Error at ';': Expect expression\n",
+		},
+		{
+			source: "print " + strings.Repeat("-", 1000000) + "1;",
+			want:   "[line 1] Error at '-': Expression nested too deeply.\n",
+		},
+		{
+			source: "f(1;",
+			want:   "[line 1] Error at ';': Expect ')' after arguments.\n",
+		},
+		{
+			source: "f(" + strings.Repeat("1, ", 256) + "1);",
+			want:   "[line 1] Error at '1': Can't have more than 255 arguments.\n",
+		},
+		{
+			source: "print 1\n2;",
+			want:   "[line 2] Error at '2': Expect ';' after value.\n",
+		},
+	}
+
+	for _, test := range tests {
+		sc := scanner.NewScanner([]byte(test.source))
+		sc.Tokenize()
+		p := NewParser(sc.GetTokens())
+		_, err := p.Parse()
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Parse(%q) error = %v, want %q", test.source, err, test.want)
+		}
+	}
+}
+
+func TestSpans(t *testing.T) {
+	sc := scanner.NewScanner([]byte("print 1;\n(2 +\n  -3) == \"${4}\";"))
+	sc.Tokenize()
+	p := NewParser(sc.GetTokens())
+	statements, err := p.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expr := statements[1].(Expression).Expression.(Binary)
+	tests := []struct {
+		node interface{ Span() scanner.Span }
+		want scanner.Span
+	}{
+		{
+			node: statements[0],
+			want: scanner.Span{Start: scanner.Position{Line: 1, Column: 1, Offset: 0}, End: scanner.Position{Line: 1, Column: 9, Offset: 8}},
+		},
+		{
+			node: statements[1],
+			want: scanner.Span{Start: scanner.Position{Line: 2, Column: 1, Offset: 9}, End: scanner.Position{Line: 3, Column: 17, Offset: 30}},
+		},
+		{
+			node: expr.Left,
+			want: scanner.Span{Start: scanner.Position{Line: 2, Column: 1, Offset: 9}, End: scanner.Position{Line: 3, Column: 6, Offset: 19}},
+		},
+		{
+			node: expr.Left.(Grouping).Expression.(Binary).Right,
+			want: scanner.Span{Start: scanner.Position{Line: 3, Column: 3, Offset: 16}, End: scanner.Position{Line: 3, Column: 5, Offset: 18}},
+		},
+		{
+			node: expr.Right,
+			want: scanner.Span{Start: scanner.Position{Line: 3, Column: 10, Offset: 23}, End: scanner.Position{Line: 3, Column: 16, Offset: 29}},
+		},
+	}
+
+	for _, test := range tests {
+		if got := test.node.Span(); got != test.want {
+			t.Errorf("Span() = %+v, want %+v", got, test.want)
+		}
+	}
+}
+
+func TestParseExprOrProgram(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{source: "(1 + 2)", want: []string{"(group (+ 1.0 2.0))"}},
+		{source: "print 1;\n2 * 3;", want: []string{"(print 1.0)", "(; (* 2.0 3.0))"}},
+		{source: "1;", want: []string{"(; 1.0)"}},
+	}
+
+	for _, test := range tests {
+		sc := scanner.NewScanner([]byte(test.source))
+		sc.Tokenize()
+		expr, statements, err := ParseExprOrProgram(sc.GetTokens())
+		if err != nil {
+			t.Fatalf("ParseExprOrProgram(%q) error = %v", test.source, err)
+		}
+
+		got := []string{}
+		if expr != nil {
+			got = append(got, NewAstPrinter().Print(expr))
+		}
+		for _, stmt := range statements {
+			got = append(got, NewAstPrinter().PrintStmt(stmt))
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseExprOrProgram(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+
+	errors := []struct {
+		source string
+		want   string
+	}{
+		{source: "1 + 2 )", want: "[line 1] Error at ')': Expect ';' after expression.\n"},
+		{source: "1 2", want: "[line 1] Error at '2': Expect ';' after expression.\n"},
+		{source: "1 + 2; print 3", want: "[line 1] Error at end: Expect ';' after value.\n"},
+		{source: "(1 + ;", want: "[line 1] Error at ';': Expect expression\n"},
+		{source: "* 3", want: "[line 1] Error at '*': Missing left-hand operand.\n"},
+	}
+
+	for _, test := range errors {
+		sc := scanner.NewScanner([]byte(test.source))
+		sc.Tokenize()
+		expr, statements, err := ParseExprOrProgram(sc.GetTokens())
+		if err == nil || err.Error() != test.want || expr != nil || statements != nil {
+			t.Errorf("ParseExprOrProgram(%q) = %v, %v, %v, want error %q", test.source, expr, statements, err, test.want)
 		}
 	}
 }
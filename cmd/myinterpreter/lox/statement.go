@@ -1,29 +1,43 @@
 package lox
 
+import (
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+)
+
+// Stmt is implemented by the statement nodes of this package, visit them
+// with AcceptStmt. Span is the source range of the whole statement,
+// including the semicolon.
 type Stmt interface {
-	Accept(v Visitor) interface{}
+	Span() scanner.Span
+	stmtNode()
 }
 
 type Expression struct {
 	Expression Expr
+	span       scanner.Span
 }
 
 func NewExpression(expression Expr) Expression {
 	return Expression{Expression: expression}
 }
 
-func (e Expression) Accept(v Visitor) interface{} {
-	return v.visitExpressionStmt(e)
+func (e Expression) stmtNode() {}
+
+func (e Expression) Span() scanner.Span {
+	return e.span
 }
 
 type Print struct {
 	Expression Expr
+	span       scanner.Span
 }
 
 func NewPrint(expression Expr) Print {
 	return Print{Expression: expression}
 }
 
-func (p Print) Accept(v Visitor) interface{} {
-	return v.visitPrintStmt(p)
+func (p Print) stmtNode() {}
+
+func (p Print) Span() scanner.Span {
+	return p.span
 }
@@ -1,11 +1,53 @@
 package lox
 
-type Visitor interface {
-	visitLiteralExpr(literal Expr) interface{}
-	visitGroupingExpr(grouping Expr) interface{}
-	visitUnaryExpr(unary Expr) interface{}
-	visitBinaryExpr(binary Expr) interface{}
-
-	visitExpressionStmt(expression Stmt) interface{}
-	visitPrintStmt(print Stmt) interface{}
+import "fmt"
+
+// Visitor is implemented by every pass over the syntax tree, R being what
+// the pass computes for each node
+type Visitor[R any] interface {
+	VisitLiteralExpr(literal Literal) R
+	VisitGroupingExpr(grouping Grouping) R
+	VisitUnaryExpr(unary Unary) R
+	VisitBinaryExpr(binary Binary) R
+	VisitConditionalExpr(conditional Conditional) R
+	VisitVariableExpr(variable Variable) R
+	VisitCallExpr(call Call) R
+	VisitInterpolationExpr(interpolation Interpolation) R
+
+	VisitExpressionStmt(expression Expression) R
+	VisitPrintStmt(print Print) R
+}
+
+func AcceptExpr[R any](expr Expr, v Visitor[R]) R {
+	switch e := expr.(type) {
+	case Literal:
+		return v.VisitLiteralExpr(e)
+	case Grouping:
+		return v.VisitGroupingExpr(e)
+	case Unary:
+		return v.VisitUnaryExpr(e)
+	case Binary:
+		return v.VisitBinaryExpr(e)
+	case Conditional:
+		return v.VisitConditionalExpr(e)
+	case Variable:
+		return v.VisitVariableExpr(e)
+	case Call:
+		return v.VisitCallExpr(e)
+	case Interpolation:
+		return v.VisitInterpolationExpr(e)
+	}
+
+	panic(fmt.Sprintf("unknown expression %T", expr))
+}
+
+func AcceptStmt[R any](stmt Stmt, v Visitor[R]) R {
+	switch s := stmt.(type) {
+	case Expression:
+		return v.VisitExpressionStmt(s)
+	case Print:
+		return v.VisitPrintStmt(s)
+	}
+
+	panic(fmt.Sprintf("unknown statement %T", stmt))
 }
@@ -0,0 +1,89 @@
+package loxvm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/lox"
+)
+
+// ToValue converts a Go value to a Lox one: nil, booleans, strings and
+// callables are kept, every integer and floating point type becomes a float64
+func ToValue(v interface{}) (Value, error) {
+	switch v := v.(type) {
+	case nil, bool, float64, string, lox.Callable:
+		return v, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %T to a Lox value", v)
+}
+
+// FromValue converts a Lox value to T. Numbers convert to any numeric type
+// they fit in exactly, nil to the zero value of any type that can be nil,
+// other values only to their own type or an interface they implement.
+func FromValue[T any](v Value) (T, error) {
+	var t T
+	if converted, ok := v.(T); ok {
+		return converted, nil
+	}
+
+	target := reflect.ValueOf(&t).Elem()
+	if v == nil {
+		switch target.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			return t, nil
+		}
+	}
+	if n, ok := v.(float64); ok {
+		switch target.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n == math.Trunc(n) && !target.OverflowInt(int64(n)) && math.Abs(n) < math.MaxInt64 {
+				target.SetInt(int64(n))
+				return t, nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if n == math.Trunc(n) && n >= 0 && n < math.MaxUint64 && !target.OverflowUint(uint64(n)) {
+				target.SetUint(uint64(n))
+				return t, nil
+			}
+		case reflect.Float32:
+			target.SetFloat(n)
+			return t, nil
+		}
+	}
+
+	return t, fmt.Errorf("cannot convert %s to %s", typeName(v), target.Type())
+}
+
+// typeName is the name of the type of a Lox value, as Lox programmers know it
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case lox.Callable:
+		return "function"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
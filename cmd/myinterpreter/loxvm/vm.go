@@ -0,0 +1,174 @@
+// Package loxvm embeds the Lox interpreter in Go programs. Unlike the command
+// line it has no process-level side effects: nothing is written to the
+// standard streams unless asked for, and errors are returned, never exited on.
+package loxvm
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/lox"
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
+)
+
+// Value is a Lox value: nil, bool, float64, string or a lox.Callable
+type Value = lox.Value
+
+type Option = lox.InterpreterOption
+
+// Capability is an access to the outside world a native function needs
+type Capability = lox.Capability
+
+const (
+	Filesystem  = lox.Filesystem
+	Network     = lox.Network
+	Clock       = lox.Clock
+	Environment = lox.Environment
+)
+
+// WithOutput sets where print writes, output is discarded by default
+func WithOutput(w io.Writer) Option {
+	return lox.WithOutput(w)
+}
+
+// WithDiagnostics sets where runtime errors are reported besides being
+// returned, they are not reported by default
+func WithDiagnostics(w io.Writer) Option {
+	return lox.WithDiagnostics(w)
+}
+
+// WithStepLimit stops each Eval or Call after n steps, a step being a
+// statement or a call. The error is a util.RuntimeError of kind
+// util.BudgetExceeded.
+func WithStepLimit(n int) Option {
+	return lox.WithStepLimit(n)
+}
+
+// WithMaxDepth sets how deep calls, including those of natives calling back
+// with Call, can nest before the program stops with a "Stack overflow."
+// runtime error. 0 keeps the default depth of 10000.
+func WithMaxDepth(n int) Option {
+	return lox.WithMaxDepth(n)
+}
+
+// WithMemoryLimit stops each Eval or Call once it allocated more than n
+// bytes, with a util.RuntimeError of kind util.OutOfMemory
+func WithMemoryLimit(n int) Option {
+	return lox.WithMemoryLimit(n)
+}
+
+// WithFileName names the file the scripts come from in stack traces
+func WithFileName(name string) Option {
+	return lox.WithFileName(name)
+}
+
+// WithCapabilities grants only the given capabilities to the natives, every
+// one is granted by default
+func WithCapabilities(capabilities ...Capability) Option {
+	return lox.WithCapabilities(capabilities...)
+}
+
+// WithSandbox grants no capability, natives touching the filesystem,
+// network, clock or environment cannot be called
+func WithSandbox() Option {
+	return lox.WithSandbox()
+}
+
+// VM is a Lox interpreter whose globals persist across calls to Eval. It is
+// not safe for concurrent use.
+type VM struct {
+	interpreter *lox.Interpreter
+}
+
+func New(opts ...Option) *VM {
+	defaults := []Option{lox.WithOutput(io.Discard), lox.WithDiagnostics(io.Discard)}
+	return &VM{interpreter: lox.NewInterpreter(append(defaults, opts...)...)}
+}
+
+// Eval runs the source, either a program or exactly one expression, and returns
+// the value of its last statement if it is an expression. The error is a
+// scanner.ScanErrors, a lox.ParseErrors or a util.RuntimeError; the program
+// is stopped with a util.BudgetExceeded one, wrapping the error of ctx, once
+// ctx is done.
+func (vm *VM) Eval(ctx context.Context, source string) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sc := scanner.NewScanner([]byte(source))
+	sc.Tokenize()
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	expr, statements, err := lox.ParseExprOrProgram(sc.GetTokens())
+	if err != nil {
+		return nil, err
+	}
+
+	if expr != nil {
+		statements = []lox.Stmt{lox.NewExpression(expr)}
+	}
+
+	defer vm.interpreter.SetContext(vm.interpreter.Context())
+	vm.interpreter.SetContext(ctx)
+
+	return vm.interpreter.Run(statements)
+}
+
+// Call is CallContext with a context that is never done
+func (vm *VM) Call(name string, args ...interface{}) (Value, error) {
+	return vm.CallContext(context.Background(), name, args...)
+}
+
+// CallContext calls the global function name with the arguments converted
+// by ToValue. The call is stopped like Eval once ctx is done.
+func (vm *VM) CallContext(ctx context.Context, name string, args ...interface{}) (Value, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	callee, ok := vm.interpreter.Global(name)
+	if !ok {
+		name := scanner.Token{TokenType: scanner.IDENTIFIER, Lexeme: name}
+		return nil, util.NewRuntimeError(name, fmt.Sprintf("Undefined variable '%s'.", name.Lexeme))
+	}
+
+	arguments := []Value{}
+	for _, arg := range args {
+		argument, err := ToValue(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		arguments = append(arguments, argument)
+	}
+
+	defer vm.interpreter.SetContext(vm.interpreter.Context())
+	vm.interpreter.SetContext(ctx)
+
+	return vm.interpreter.Call(callee, arguments)
+}
+
+func (vm *VM) GetGlobal(name string) (Value, bool) {
+	return vm.interpreter.Global(name)
+}
+
+// SetGlobal defines the global name to the value converted by ToValue
+func (vm *VM) SetGlobal(name string, value interface{}) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return err
+	}
+
+	vm.interpreter.DefineGlobal(name, v)
+	return nil
+}
+
+// DefineNative defines a global function implemented in Go, calling it
+// needs the given capabilities
+func (vm *VM) DefineNative(name string, arity int, fn func(arguments []Value) (Value, error), capabilities ...Capability) {
+	vm.interpreter.DefineNative(name, arity, fn, capabilities...)
+}
@@ -0,0 +1,205 @@
+package loxvm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/lox"
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
+	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
+)
+
+func TestEval(t *testing.T) {
+	var output bytes.Buffer
+	vm := New(WithOutput(&output))
+	if err := vm.SetGlobal("limit", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := vm.Eval(context.Background(), `print "limit ${limit}"; limit * 2;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != 6.0 {
+		t.Errorf("Eval() = %v, want 6", value)
+	}
+
+	if got, want := output.String(), "limit 3\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+
+	value, err = vm.Eval(context.Background(), `limit > 2 ? "big" : "small"`)
+	if err != nil || value != "big" {
+		t.Errorf("Eval() = %v, %v, want big", value, err)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vm := New()
+
+	var scanErrs scanner.ScanErrors
+	if _, err := vm.Eval(context.Background(), `print "a;`); !errors.As(err, &scanErrs) {
+		t.Errorf("Eval() error = %v, want scan errors", err)
+	}
+
+	var output bytes.Buffer
+	vm = New(WithOutput(&output))
+	for _, source := range []string{`print 1`, `1 2`, `1 + 2; print 3`, `1 + 2 )`} {
+		var parseErrs lox.ParseErrors
+		if value, err := vm.Eval(context.Background(), source); !errors.As(err, &parseErrs) || value != nil {
+			t.Errorf("Eval(%q) = %v, %v, want parse errors", source, value, err)
+		}
+	}
+
+	if output.Len() != 0 {
+		t.Errorf("output = %q, want nothing run", output.String())
+	}
+
+	var runtimeErr util.RuntimeError
+	if _, err := vm.Eval(context.Background(), `-"a";`); !errors.As(err, &runtimeErr) {
+		t.Errorf("Eval() error = %v, want runtime error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := vm.Eval(ctx, `1;`); !errors.Is(err, context.Canceled) {
+		t.Errorf("Eval() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStepLimit(t *testing.T) {
+	vm := New(WithStepLimit(2))
+	if _, err := vm.Eval(context.Background(), "1; 2;"); err != nil {
+		t.Fatal(err)
+	}
+
+	var runtimeErr util.RuntimeError
+	_, err := vm.Eval(context.Background(), "1; 2; 3;")
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind() != util.BudgetExceeded {
+		t.Errorf("Eval() error = %v, want budget exceeded", err)
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	vm := New(WithSandbox())
+	_, err := vm.Eval(context.Background(), "clock();")
+	if err == nil || err.Error() != "Native function 'clock' needs the clock capability.\n[line 1]\n  at clock (line 1)" {
+		t.Errorf("Eval() error = %v, want denied capability", err)
+	}
+
+	vm = New(WithCapabilities(Clock))
+	if _, err := vm.Eval(context.Background(), "clock();"); err != nil {
+		t.Errorf("Eval() error = %v, want nil", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	var diagnostics bytes.Buffer
+	vm := New(WithDiagnostics(&diagnostics))
+	vm.DefineNative("scale", 2, func(arguments []Value) (Value, error) {
+		return arguments[0].(float64) * arguments[1].(float64), nil
+	})
+
+	value, err := vm.Call("scale", 4, uint8(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := FromValue[int](value)
+	if err != nil || n != 8 {
+		t.Errorf("FromValue[int](%v) = %v, %v, want 8", value, n, err)
+	}
+
+	// the same runtime errors as Eval, without a line outside of the source
+	var runtimeErr util.RuntimeError
+	if _, err := vm.Call("scale", 1); !errors.As(err, &runtimeErr) || err.Error() != "Expected 2 arguments but got 1." {
+		t.Errorf("Call() error = %v, want arity error", err)
+	}
+
+	if err := vm.SetGlobal("limit", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Call("limit"); !errors.As(err, &runtimeErr) || err.Error() != "Can only call functions and classes." {
+		t.Errorf("Call() error = %v, want not callable error", err)
+	}
+
+	if !strings.HasPrefix(diagnostics.String(), "Expected 2 arguments but got 1.\n") {
+		t.Errorf("diagnostics = %q, want the errors reported", diagnostics.String())
+	}
+
+	if _, err := vm.Call("missing"); !errors.As(err, &runtimeErr) || err.Error() != "Undefined variable 'missing'." {
+		t.Errorf("Call() error = %v, want undefined variable", err)
+	}
+
+	if _, ok := vm.GetGlobal("clock"); !ok {
+		t.Error("GetGlobal(clock) is not defined")
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	vm := New()
+	ctx, cancel := context.WithCancel(context.Background())
+	vm.DefineNative("cancel", 0, func(arguments []Value) (Value, error) {
+		cancel()
+		return vm.Call("clock")
+	})
+
+	// the nested call runs without ctx, the outer run gets it back after
+	_, err := vm.Eval(ctx, "cancel();\n1;")
+	var runtimeErr util.RuntimeError
+	if !errors.As(err, &runtimeErr) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Eval() error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := vm.CallContext(ctx, "clock"); !errors.Is(err, context.Canceled) {
+		t.Errorf("CallContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	if _, err := vm.Call("clock"); err != nil {
+		t.Errorf("Call() error = %v, want nil", err)
+	}
+}
+
+func TestConversion(t *testing.T) {
+	if _, err := ToValue([]int{1}); err == nil {
+		t.Error("ToValue([]int) error = nil, want error")
+	}
+
+	if _, err := FromValue[int](2.5); err == nil {
+		t.Error("FromValue[int](2.5) error = nil, want error")
+	}
+
+	if _, err := FromValue[uint8](256.0); err == nil {
+		t.Error("FromValue[uint8](256) error = nil, want error")
+	}
+
+	if s, err := FromValue[string]("a"); err != nil || s != "a" {
+		t.Errorf("FromValue[string](a) = %q, %v", s, err)
+	}
+
+	if _, err := FromValue[string](1.0); err == nil || err.Error() != "cannot convert number to string" {
+		t.Errorf("FromValue[string](1) error = %v", err)
+	}
+
+	// nil converts to whatever can hold it
+	if v, err := FromValue[any](nil); err != nil || v != nil {
+		t.Errorf("FromValue[any](nil) = %v, %v, want nil", v, err)
+	}
+
+	if p, err := FromValue[*int](nil); err != nil || p != nil {
+		t.Errorf("FromValue[*int](nil) = %v, %v, want nil", p, err)
+	}
+
+	if s, err := FromValue[[]string](nil); err != nil || s != nil {
+		t.Errorf("FromValue[[]string](nil) = %v, %v, want nil", s, err)
+	}
+
+	if _, err := FromValue[int](nil); err == nil {
+		t.Error("FromValue[int](nil) error = nil, want error")
+	}
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/lox"
 	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/scanner"
-	"github.com/codecrafters-io/interpreter-starter-go/cmd/myinterpreter/util"
 )
 
 func readFileAndScan(filename string) []scanner.Token {
@@ -19,11 +19,8 @@ func readFileAndScan(filename string) []scanner.Token {
 	sc := scanner.NewScanner(fileContents)
 	sc.Tokenize()
 	tokens := sc.GetTokens()
-	errorSlice := sc.GetErrors()
-	if len(errorSlice) > 0 {
-		for _, e := range errorSlice {
-			fmt.Fprint(os.Stderr, e+"\n")
-		}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(65)
 	}
 
@@ -39,7 +36,16 @@ func main() {
 	command := os.Args[1]
 
 	if command == "tokenize" {
-		filename := os.Args[2]
+		flags := flag.NewFlagSet("tokenize", flag.ExitOnError)
+		format := flags.String("format", "text", "output format: text, json or csv")
+		flags.Parse(os.Args[2:])
+
+		if flags.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh tokenize [--format=text|json|csv] <filename>")
+			os.Exit(1)
+		}
+
+		filename := flags.Arg(0)
 		fileContents, err := os.ReadFile(filename)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
@@ -48,38 +54,131 @@ func main() {
 
 		sc := scanner.NewScanner(fileContents)
 		sc.Tokenize()
-		stringSlice := sc.GetTokensString()
-		errorSlice := sc.GetErrors()
-		if len(errorSlice) > 0 {
-			for _, e := range errorSlice {
-				fmt.Fprint(os.Stderr, e+"\n")
+		scanErr := sc.Err()
+		if scanErr != nil {
+			fmt.Fprintln(os.Stderr, scanErr)
+		}
+
+		switch *format {
+		case "text":
+			for _, s := range sc.GetTokensString() {
+				fmt.Println(s)
 			}
+		case "json":
+			err = scanner.WriteTokensJSON(os.Stdout, sc.GetTokens())
+		case "csv":
+			err = scanner.WriteTokensCSV(os.Stdout, sc.GetTokens())
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+			os.Exit(1)
 		}
 
-		for _, s := range stringSlice {
-			fmt.Println(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing tokens: %v\n", err)
+			os.Exit(1)
 		}
 
-		if len(errorSlice) > 0 {
+		if scanErr != nil {
 			os.Exit(65)
 		} else {
 			os.Exit(0)
 		}
 	} else if command == "parse" {
-		tokens := readFileAndScan(os.Args[2])
+		flags := flag.NewFlagSet("parse", flag.ExitOnError)
+		format := flags.String("format", "text", "output format: text, json or dot")
+		flags.Parse(os.Args[2:])
+
+		if flags.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh parse [--format=text|json|dot] <filename>")
+			os.Exit(1)
+		}
+
+		tokens := readFileAndScan(flags.Arg(0))
 		if len(tokens) == 0 {
 			fmt.Println("No tokens found")
 			os.Exit(0)
 		}
 
-		p := lox.NewParser(tokens)
-		expr, err := p.ParseExpr()
+		expr, statements, err := lox.ParseExprOrProgram(tokens)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%v\n", err)
 			os.Exit(65)
 		}
 
-		fmt.Println(lox.NewAstPrinter().Print(expr))
+		switch *format {
+		case "text":
+			if expr != nil {
+				fmt.Println(lox.NewAstPrinter().Print(expr))
+			} else {
+				for _, stmt := range statements {
+					fmt.Println(lox.NewAstPrinter().PrintStmt(stmt))
+				}
+			}
+		case "json":
+			var data []byte
+			if expr != nil {
+				data, err = lox.MarshalExpr(expr)
+			} else {
+				data, err = lox.MarshalStmts(statements)
+			}
+
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error encoding AST: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Println(string(data))
+		case "dot":
+			if expr != nil {
+				fmt.Print(lox.NewDotPrinter().PrintExpr(expr))
+			} else {
+				fmt.Print(lox.NewDotPrinter().PrintStmts(statements))
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown format: %s\n", *format)
+			os.Exit(1)
+		}
+	} else if command == "fmt" {
+		flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+		check := flags.Bool("check", false, "exit with status 1 if the file is not formatted")
+		write := flags.Bool("write", false, "write the result back to the file")
+		width := flags.Int("width", 80, "maximum line width, 0 for no limit")
+		flags.Parse(os.Args[2:])
+
+		if flags.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: ./your_program.sh fmt [--check] [--write] [--width=80] <filename>")
+			os.Exit(1)
+		}
+
+		filename := flags.Arg(0)
+		fileContents, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+			os.Exit(1)
+		}
+
+		formatted, err := lox.Format(fileContents, *width)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(65)
+		}
+
+		changed := formatted != string(fileContents)
+		if *write && changed {
+			if err := os.WriteFile(filename, []byte(formatted), 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing file: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		if *check {
+			if changed {
+				fmt.Fprintf(os.Stderr, "%s is not formatted\n", filename)
+				os.Exit(1)
+			}
+		} else if !*write {
+			fmt.Print(formatted)
+		}
 	} else if command == "evaluate" {
 		tokens := readFileAndScan(os.Args[2])
 		if len(tokens) == 0 {
@@ -94,22 +193,13 @@ func main() {
 			os.Exit(65)
 		}
 
-		interpreter := lox.NewInterpreter()
-
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", r.(util.RuntimeError).Error())
-				os.Exit(70)
-			}
-		}()
-
-		values := interpreter.Evaluate(expr)
-
-		if values == nil {
-			fmt.Println("nil")
-		} else {
-			fmt.Printf("%v", values)
+		interpreter := lox.NewInterpreter(lox.WithFileName(os.Args[2]))
+		value, err := interpreter.Run([]lox.Stmt{lox.NewExpression(expr)})
+		if err != nil {
+			os.Exit(70)
 		}
+
+		fmt.Println(lox.Stringify(value))
 	} else if command == "run" {
 		tokens := readFileAndScan(os.Args[2])
 		if len(tokens) == 0 {
@@ -124,16 +214,10 @@ func main() {
 			os.Exit(65)
 		}
 
-		interpreter := lox.NewInterpreter()
-
-		defer func() {
-			if r := recover(); r != nil {
-				fmt.Fprintf(os.Stderr, "%v\n", r.(util.RuntimeError).Error())
-				os.Exit(70)
-			}
-		}()
-
-		interpreter.Interpret(statements)
+		interpreter := lox.NewInterpreter(lox.WithFileName(os.Args[2]))
+		if err := interpreter.Interpret(statements); err != nil {
+			os.Exit(70)
+		}
 	} else if command == "parse_test" {
 		b := lox.NewBinary(
 			lox.NewLiteral(1),
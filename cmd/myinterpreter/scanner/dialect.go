@@ -0,0 +1,81 @@
+package scanner
+
+// Dialect is the set of keywords and operators recognised by a scanner, it
+// must not be changed while a scanner using it is tokenizing.
+type Dialect struct {
+	keywords        map[string]TokenType
+	operators       map[string]TokenType
+	longestOperator int
+	blockComments   bool
+}
+
+var defaultDialect = NewDialect()
+
+// NewDialect returns a copy of the standard Lox dialect to build upon
+func NewDialect() *Dialect {
+	d := &Dialect{
+		keywords:  map[string]TokenType{},
+		operators: map[string]TokenType{},
+	}
+
+	for word, t := range keywords {
+		d.AddKeyword(word, t)
+	}
+
+	for lexeme, t := range operators {
+		d.AddOperator(lexeme, t)
+	}
+
+	return d
+}
+
+func (d *Dialect) AddKeyword(word string, t TokenType) *Dialect {
+	d.keywords[word] = t
+	return d
+}
+
+// RemoveKeyword turns word back into a plain identifier
+func (d *Dialect) RemoveKeyword(word string) *Dialect {
+	delete(d.keywords, word)
+	return d
+}
+
+// AddOperator adds a symbolic token, when operators share a prefix the
+// longest one wins, e.g. ** over *
+func (d *Dialect) AddOperator(lexeme string, t TokenType) *Dialect {
+	d.operators[lexeme] = t
+	d.longestOperator = max(d.longestOperator, len(lexeme))
+	return d
+}
+
+// RemoveOperator makes lexeme an unexpected character again, comments are
+// not operators and cannot be removed
+func (d *Dialect) RemoveOperator(lexeme string) *Dialect {
+	delete(d.operators, lexeme)
+
+	d.longestOperator = 0
+	for l := range d.operators {
+		d.longestOperator = max(d.longestOperator, len(l))
+	}
+
+	return d
+}
+
+// AddBlockComments makes the scanner skip /* */ comments, which standard Lox
+// scans as a slash and a star
+func (d *Dialect) AddBlockComments() *Dialect {
+	d.blockComments = true
+	return d
+}
+
+// lookahead is how many bytes past the end of a token the scanner may have
+// read to decide where the token ends
+func (d *Dialect) lookahead() int {
+	return max(1, d.longestOperator-1)
+}
+
+func WithDialect(d *Dialect) Option {
+	return func(s *Scanner) {
+		s.dialect = d
+	}
+}
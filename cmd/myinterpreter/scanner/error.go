@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ErrorCode int
+
+const (
+	UnterminatedString ErrorCode = iota + 1
+	UnexpectedCharacter
+	InvalidNumber
+	UnterminatedComment
+)
+
+var errorCodeNames = map[ErrorCode]string{
+	UnterminatedString:  "unterminated-string",
+	UnexpectedCharacter: "unexpected-character",
+	InvalidNumber:       "invalid-number",
+	UnterminatedComment: "unterminated-comment",
+}
+
+// Code returns the stable identifier of the error, e.g. E0001
+func (c ErrorCode) Code() string {
+	return fmt.Sprintf("E%04d", int(c))
+}
+
+func (c ErrorCode) Name() string {
+	return errorCodeNames[c]
+}
+
+func (c ErrorCode) String() string {
+	return c.Code() + " " + c.Name()
+}
+
+// ScanError is a lexical error covering the bytes [Offset, End) of the source
+type ScanError struct {
+	Code    ErrorCode
+	Message string
+	Line    int
+	Column  int
+	Offset  int
+	End     int
+}
+
+func (e ScanError) Error() string {
+	return fmt.Sprintf("[line %d] Error: %s", e.Line, e.Message)
+}
+
+// ScanErrors is the list of every error found while scanning a source
+type ScanErrors []ScanError
+
+func (e ScanErrors) Error() string {
+	messages := []string{}
+	for _, se := range e {
+		messages = append(messages, se.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e ScanErrors) Unwrap() []error {
+	errors := []error{}
+	for _, se := range e {
+		errors = append(errors, se)
+	}
+
+	return errors
+}
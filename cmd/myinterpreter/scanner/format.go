@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// TokenRecord is the structured form of a token used by the json and csv
+// outputs of the tokenize command
+type TokenRecord struct {
+	Type    string      `json:"type"`
+	Lexeme  string      `json:"lexeme"`
+	Literal interface{} `json:"literal"`
+	Line    int         `json:"line"`
+	Column  int         `json:"column"`
+	Offset  int         `json:"offset"`
+	End     int         `json:"end"`
+}
+
+func NewTokenRecord(t Token) TokenRecord {
+	var literal interface{}
+	if t.TokenType == NUMBER || t.TokenType == STRING {
+		literal = t.Literal
+	}
+
+	return TokenRecord{
+		Type:    t.TokenType.String(),
+		Lexeme:  t.Lexeme,
+		Literal: literal,
+		Line:    t.Line,
+		Column:  t.Column,
+		Offset:  t.Offset,
+		End:     t.Offset + len(t.Lexeme),
+	}
+}
+
+func WriteTokensJSON(w io.Writer, tokens []Token) error {
+	records := []TokenRecord{}
+	for _, t := range tokens {
+		records = append(records, NewTokenRecord(t))
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+func WriteTokensCSV(w io.Writer, tokens []Token) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"type", "lexeme", "literal", "line", "column", "offset", "end"})
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tokens {
+		r := NewTokenRecord(t)
+
+		literal := ""
+		if r.Literal != nil {
+			literal = fmt.Sprintf("%v", HandleNumberLiteral(r.Literal))
+		}
+
+		err = writer.Write([]string{
+			r.Type,
+			r.Lexeme,
+			literal,
+			strconv.Itoa(r.Line),
+			strconv.Itoa(r.Column),
+			strconv.Itoa(r.Offset),
+			strconv.Itoa(r.End),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
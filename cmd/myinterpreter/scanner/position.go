@@ -0,0 +1,34 @@
+package scanner
+
+import "strings"
+
+// Position is a location in the source, Column is 1-based and counted in bytes
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Offset int `json:"offset"`
+}
+
+// Span is the range of source [Start, End) covered by one or more tokens
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (t Token) Start() Position {
+	return Position{Line: t.Line, Column: t.Column, Offset: t.Offset}
+}
+
+// End is the position right after the lexeme
+func (t Token) End() Position {
+	column := t.Column + len(t.Lexeme)
+	if i := strings.LastIndexByte(t.Lexeme, '\n'); i >= 0 {
+		column = len(t.Lexeme) - i
+	}
+
+	return Position{Line: t.Line, Column: column, Offset: t.Offset + len(t.Lexeme)}
+}
+
+func TokenSpan(from Token, to Token) Span {
+	return Span{Start: from.Start(), End: to.End()}
+}
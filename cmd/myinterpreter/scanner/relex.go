@@ -0,0 +1,154 @@
+package scanner
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Edit replaces Deleted bytes of the source starting at Offset with Inserted
+type Edit struct {
+	Offset   int
+	Deleted  int
+	Inserted string
+}
+
+// Relex applies the edit to the source of the scanner and returns the tokens
+// of the edited source. prev must be the tokens of the source before the edit,
+// as returned by Tokenize or a previous Relex.
+//
+// Scanning restarts after the last token that ends before the edit, and stops as
+// soon as it produces a token identical to one of prev located after the edit.
+// From there on the old tokens (and errors) are reused, shifted by the size
+// of the edit.
+func (s *Scanner) Relex(prev []Token, edit Edit) []Token {
+	editEnd := edit.Offset + edit.Deleted
+	delta := len(edit.Inserted) - edit.Deleted
+
+	source := make([]byte, 0, len(s.source)+delta)
+	source = append(source, s.source[:edit.Offset]...)
+	source = append(source, edit.Inserted...)
+	source = append(source, s.source[editEnd:]...)
+
+	// keep the tokens ending far enough before the edit that their lookahead
+	// did not reach into it, and restart scanning right after the last of them
+	lookahead := s.dialect.lookahead()
+	keep := 0
+	for keep < len(prev)-1 && prev[keep].Offset+len(prev[keep].Lexeme)+lookahead <= edit.Offset {
+		keep++
+	}
+
+	// scanning can only restart or resynchronise outside of interpolations
+	open := openInterpolations(prev)
+	for keep > 0 && open[keep-1] > 0 {
+		keep--
+	}
+
+	restartOffset, restartLine := 0, 1
+	if keep > 0 {
+		last := prev[keep-1]
+		restartOffset, restartLine = last.Offset+len(last.Lexeme), last.Line
+	}
+
+	oldErrors := s.errors
+	s.source = source
+	s.tokens = append([]Token{}, prev[:keep]...)
+	s.errors = []ScanError{}
+	for _, e := range oldErrors {
+		if e.Offset < restartOffset {
+			s.errors = append(s.errors, e)
+		}
+	}
+	s.start, s.current, s.line = restartOffset, restartOffset, restartLine
+	s.interpolations = nil
+	s.lineStart = bytes.LastIndexByte(s.source[:restartOffset], '\n') + 1
+
+	// old tokens after the edit, candidates to resynchronise with
+	next := keep
+	for next < len(prev)-1 && prev[next].Offset < editEnd {
+		next++
+	}
+
+	for s.current < len(s.source) {
+		count := len(s.tokens)
+		s.scanToken()
+		if len(s.tokens) == count {
+			continue
+		}
+
+		t := s.tokens[len(s.tokens)-1]
+		for next < len(prev)-1 && prev[next].Offset+delta < t.Offset {
+			next++
+		}
+
+		old := prev[next]
+		if old.TokenType != EOF && old.Offset+delta == t.Offset && old.TokenType == t.TokenType && old.Lexeme == t.Lexeme &&
+			open[next] == 0 && len(s.interpolations) == 0 {
+			s.reuse(prev[next+1:], oldErrors, old, t, delta)
+			return s.tokens
+		}
+	}
+
+	s.finish()
+
+	return s.tokens
+}
+
+// openInterpolations returns, for each token, the number of string
+// interpolations still open after it
+func openInterpolations(tokens []Token) []int {
+	open := make([]int, len(tokens))
+	count := 0
+	for i, t := range tokens {
+		continuation := strings.HasPrefix(t.Lexeme, "}")
+		if t.TokenType == INTERPOLATION && !continuation {
+			count++
+		} else if t.TokenType == STRING && continuation {
+			count--
+		}
+
+		open[i] = count
+	}
+
+	return open
+}
+
+// reuse appends the old tokens and errors following the resynchronisation
+// point, old being the token of the previous scan matching the freshly
+// scanned t
+func (s *Scanner) reuse(rest []Token, oldErrors []ScanError, old Token, t Token, delta int) {
+	lineDelta := t.Line - old.Line
+
+	// columns only change up to the end of the line the edit happened on
+	end := t.Offset + len(t.Lexeme)
+	lineEnd := len(s.source)
+	if i := bytes.IndexByte(s.source[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+
+	for _, r := range rest {
+		r.Offset += delta
+		r.Line += lineDelta
+		if r.Offset <= lineEnd {
+			r.Column = s.column(r.Offset)
+		}
+
+		s.tokens = append(s.tokens, r)
+	}
+
+	for _, e := range oldErrors {
+		if e.Offset > old.Offset {
+			e.Offset += delta
+			e.End += delta
+			e.Line += lineDelta
+			if e.Offset <= lineEnd {
+				e.Column = s.column(e.Offset)
+			}
+
+			s.errors = append(s.errors, e)
+		}
+	}
+
+	if s.preserveTrivia {
+		s.attachTrivia()
+	}
+}
@@ -1,6 +1,7 @@
 package scanner
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"unicode"
@@ -21,6 +22,8 @@ const (
 	SEMICOLON
 	SLASH
 	STAR
+	QUESTION
+	COLON
 
 	// One or two character tokens
 	BANG
@@ -36,6 +39,7 @@ const (
 	IDENTIFIER
 	STRING
 	NUMBER
+	INTERPOLATION // string segment followed by ${
 
 	// Keywords
 	AND
@@ -57,6 +61,13 @@ const (
 	WHILE
 
 	EOF
+
+	// Extensions, only scanned by dialects that add them
+	PERCENT
+	STAR_STAR
+	BREAK
+	CONTINUE
+	LET
 )
 
 var keywords = map[string]TokenType{
@@ -78,17 +89,66 @@ var keywords = map[string]TokenType{
 	"while":  WHILE,
 }
 
+var operators = map[string]TokenType{
+	"(":  LEFT_PAREN,
+	")":  RIGHT_PAREN,
+	"{":  LEFT_BRACE,
+	"}":  RIGHT_BRACE,
+	",":  COMMA,
+	".":  DOT,
+	"-":  MINUS,
+	"+":  PLUS,
+	";":  SEMICOLON,
+	"/":  SLASH,
+	"*":  STAR,
+	"?":  QUESTION,
+	":":  COLON,
+	"!":  BANG,
+	"!=": BANG_EQUAL,
+	"=":  EQUAL,
+	"==": EQUAL_EQUAL,
+	">":  GREATER,
+	">=": GREATER_EQUAL,
+	"<":  LESS,
+	"<=": LESS_EQUAL,
+}
+
+var tokenTypeNames = []string{
+	"LEFT_PAREN", "RIGHT_PAREN",
+	"LEFT_BRACE", "RIGHT_BRACE",
+	"COMMA", "DOT", "MINUS", "PLUS", "SEMICOLON", "SLASH", "STAR", "QUESTION", "COLON",
+	"BANG", "BANG_EQUAL", "EQUAL", "EQUAL_EQUAL", "GREATER", "GREATER_EQUAL", "LESS", "LESS_EQUAL",
+	"IDENTIFIER", "STRING", "NUMBER", "INTERPOLATION",
+	"AND", "CLASS", "ELSE", "FALSE", "FUN", "FOR", "IF", "NIL", "OR",
+	"PRINT", "RETURN", "SUPER", "THIS", "TRUE", "VAR", "WHILE",
+	"EOF",
+	"PERCENT", "STAR_STAR", "BREAK", "CONTINUE", "LET",
+}
+
+// NewTokenType registers a token type for a dialect extension. It is not safe
+// for concurrent use and is meant to be called during initialisation.
+func NewTokenType(name string) TokenType {
+	tokenTypeNames = append(tokenTypeNames, name)
+	return TokenType(len(tokenTypeNames) - 1)
+}
+
+// LookupTokenType is the inverse of TokenType.String
+func LookupTokenType(name string) (TokenType, bool) {
+	for i, n := range tokenTypeNames {
+		if n == name {
+			return TokenType(i), true
+		}
+	}
+
+	return 0, false
+}
+
 func (t TokenType) String() string {
-	return [...]string{
-		"LEFT_PAREN", "RIGHT_PAREN",
-		"LEFT_BRACE", "RIGHT_BRACE",
-		"COMMA", "DOT", "MINUS", "PLUS", "SEMICOLON", "SLASH", "STAR",
-		"BANG", "BANG_EQUAL", "EQUAL", "EQUAL_EQUAL", "GREATER", "GREATER_EQUAL", "LESS", "LESS_EQUAL",
-		"IDENTIFIER", "STRING", "NUMBER",
-		"AND", "CLASS", "ELSE", "FALSE", "FUN", "FOR", "IF", "NIL", "OR",
-		"PRINT", "RETURN", "SUPER", "THIS", "TRUE", "VAR", "WHILE",
-		"EOF",
-	}[t]
+	if int(t) < 0 || int(t) >= len(tokenTypeNames) {
+		return fmt.Sprintf("TokenType(%d)", int(t))
+	}
+
+	return tokenTypeNames[t]
 }
 
 type Token struct {
@@ -96,6 +156,17 @@ type Token struct {
 	Lexeme    string
 	Literal   interface{}
 	Line      int
+	Column    int // 1-based, counted in bytes
+	Offset    int // byte offset of the lexeme in the source
+
+	// only filled in when the scanner is created WithTrivia
+	LeadingTrivia  string
+	TrailingTrivia string
+}
+
+// FullText returns the token together with its surrounding trivia
+func (t Token) FullText() string {
+	return t.LeadingTrivia + t.Lexeme + t.TrailingTrivia
 }
 
 func NewToken(tokenType TokenType, lexeme string, literal string) Token {
@@ -105,21 +176,46 @@ func NewToken(tokenType TokenType, lexeme string, literal string) Token {
 type Scanner struct {
 	source  []byte
 	tokens  []Token
-	errors  []string
+	errors  []ScanError
 	start   int
 	current int
 	line    int
+
+	lineStart int // offset of the first byte of the current line
+
+	// brace depth of each open string interpolation, innermost last
+	interpolations []int
+
+	preserveTrivia bool
+	dialect        *Dialect
 }
 
-func NewScanner(source []byte) *Scanner {
-	return &Scanner{
+type Option func(*Scanner)
+
+// WithTrivia keeps whitespace and comments attached to the tokens, so that
+// concatenating the FullText of every token gives back the original source
+func WithTrivia() Option {
+	return func(s *Scanner) {
+		s.preserveTrivia = true
+	}
+}
+
+func NewScanner(source []byte, opts ...Option) *Scanner {
+	s := &Scanner{
 		source:  source,
 		tokens:  []Token{},
-		errors:  []string{},
+		errors:  []ScanError{},
 		start:   0,
 		current: 0,
 		line:    1,
+		dialect: defaultDialect,
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 func (s *Scanner) addToken(tokenType TokenType) {
@@ -131,23 +227,36 @@ func (s *Scanner) addToken(tokenType TokenType) {
 		literal = lexeme[1 : len(lexeme)-1]
 	}
 
+	if tokenType == INTERPOLATION {
+		literal = lexeme[1 : len(lexeme)-2]
+	}
+
 	if tokenType == NUMBER {
 		num, err := strconv.ParseFloat(lexeme, 64)
 		if err != nil {
-			s.addError(fmt.Sprintf("Error parsing number: %s", lexeme))
+			s.addError(InvalidNumber, fmt.Sprintf("Error parsing number: %s", lexeme))
+			s.start = s.current
 			return
 		}
 
 		literal = num
 	}
 
-	s.tokens = append(s.tokens, Token{TokenType: tokenType, Lexeme: lexeme, Literal: literal, Line: s.line})
+	s.tokens = append(s.tokens, Token{TokenType: tokenType, Lexeme: lexeme, Literal: literal, Line: s.line, Column: s.column(s.start), Offset: s.start})
 
 	s.start = s.current
 }
 
-func (s *Scanner) addError(message string) {
-	s.errors = append(s.errors, fmt.Sprintf("[line %d] Error: %s", s.line, message))
+// the error spans from the start of the current lexeme to the current character
+func (s *Scanner) addError(code ErrorCode, message string) {
+	s.errors = append(s.errors, ScanError{
+		Code:    code,
+		Message: message,
+		Line:    s.line,
+		Column:  s.column(s.start),
+		Offset:  s.start,
+		End:     min(s.current+1, len(s.source)),
+	})
 }
 
 func (s *Scanner) isAtEnd() bool {
@@ -171,11 +280,22 @@ func (s *Scanner) advance() {
 }
 
 func (s *Scanner) addEOF() {
-	s.tokens = append(s.tokens, Token{TokenType: EOF, Lexeme: "", Literal: "null", Line: s.line})
+	s.tokens = append(s.tokens, Token{TokenType: EOF, Lexeme: "", Literal: "null", Line: s.line, Column: s.column(len(s.source)), Offset: len(s.source)})
+}
+
+func (s *Scanner) column(offset int) int {
+	if offset < s.lineStart {
+		// the lexeme started on an earlier line, e.g. a multi-line string
+		return offset - bytes.LastIndexByte(s.source[:offset], '\n')
+	}
+
+	return offset - s.lineStart + 1
 }
 
+// called with s.current on the newline
 func (s *Scanner) addLine() {
 	s.line += 1
+	s.lineStart = s.current + 1
 }
 
 func (s *Scanner) addNumber() {
@@ -186,6 +306,68 @@ func (s *Scanner) addNumber() {
 	s.addToken(NUMBER)
 }
 
+// addString scans a string, or the rest of one after an interpolation, up to
+// the closing quote or the next ${
+func (s *Scanner) addString() {
+	for {
+		if s.isAtEnd() {
+			s.addError(UnterminatedString, "Unterminated string.")
+			return
+		}
+		s.advance()
+
+		switch s.source[s.current] {
+		case '"':
+			s.addToken(STRING)
+			return
+		case '$':
+			if s.nextMatch('{') {
+				s.advance()
+				s.addToken(INTERPOLATION)
+				s.interpolations = append(s.interpolations, 0)
+				return
+			}
+		case '\n':
+			// newlines in strings do not count as lines, but columns restart
+			s.lineStart = s.current + 1
+		}
+	}
+}
+
+// addOperator adds the longest operator of the dialect starting at s.current
+func (s *Scanner) addOperator() {
+	for n := min(s.dialect.longestOperator, len(s.source)-s.current); n > 0; n-- {
+		if tokenType, ok := s.dialect.operators[string(s.source[s.current:s.current+n])]; ok {
+			s.current += n - 1
+			s.addToken(tokenType)
+			return
+		}
+	}
+
+	s.addError(UnexpectedCharacter, fmt.Sprintf("Unexpected character: %s", string(s.source[s.current])))
+}
+
+// skips a /* ... */ comment, block comments do not nest
+func (s *Scanner) addBlockComment() {
+	s.advance()
+	for {
+		if s.isAtEnd() {
+			s.addError(UnterminatedComment, "Unterminated comment.")
+			break
+		}
+
+		s.advance()
+		if s.source[s.current] == '\n' {
+			s.addLine()
+		} else if s.source[s.current] == '*' && s.nextMatch('/') {
+			s.advance()
+			break
+		}
+	}
+
+	s.start = s.current
+}
+
 func (s *Scanner) isAlpha(c byte) bool {
 	return unicode.IsLetter(rune(c)) || c == '_'
 }
@@ -205,112 +387,86 @@ func (s *Scanner) peek() byte {
 // token format: <TOKEN_TYPE> <LEXEME> <LITERAL>
 func (s *Scanner) Tokenize() {
 	for s.current < len(s.source) {
-		t := s.source[s.current]
-
-		switch t {
-		case '(':
-			s.addToken(LEFT_PAREN)
-		case ')':
-			s.addToken(RIGHT_PAREN)
-		case '{':
-			s.addToken(LEFT_BRACE)
-		case '}':
-			s.addToken(RIGHT_BRACE)
-		case ',':
-			s.addToken(COMMA)
-		case '.':
-			s.addToken(DOT)
-		case '-':
-			s.addToken(MINUS)
-		case '+':
-			s.addToken(PLUS)
-		case ';':
-			s.addToken(SEMICOLON)
-		case '*':
-			s.addToken(STAR)
-		case '=':
-			if s.nextMatch('=') {
-				s.advance()
-				s.addToken(EQUAL_EQUAL)
-			} else {
-				s.addToken(EQUAL)
-			}
-		case '!':
-			if s.nextMatch('=') {
-				s.advance()
-				s.addToken(BANG_EQUAL)
-			} else {
-				s.addToken(BANG)
-			}
-		case '<':
-			if s.nextMatch('=') {
-				s.advance()
-				s.addToken(LESS_EQUAL)
-			} else {
-				s.addToken(LESS)
-			}
-		case '>':
-			if s.nextMatch('=') {
-				s.advance()
-				s.addToken(GREATER_EQUAL)
-			} else {
-				s.addToken(GREATER)
+		s.scanToken()
+	}
+
+	s.finish()
+}
+
+func (s *Scanner) finish() {
+	if len(s.interpolations) > 0 {
+		s.addError(UnterminatedString, "Unterminated string.")
+	}
+
+	s.addEOF()
+
+	if s.preserveTrivia {
+		s.attachTrivia()
+	}
+}
+
+// scanToken consumes one lexeme (or piece of whitespace/comment) starting at s.current
+func (s *Scanner) scanToken() {
+	t := s.source[s.current]
+
+	switch t {
+	case '/':
+		if s.nextMatch('/') {
+			// it's a comment, ignore the rest of the line
+			for !s.isAtEnd() && !s.nextMatch('\n') {
+				s.start += 1
+				s.current += 1
 			}
-		case '/':
-			if s.nextMatch('/') {
-				// it's a comment, ignore the rest of the line
-				for !s.isAtEnd() && !s.nextMatch('\n') {
-					s.start += 1
-					s.current += 1
-				}
-			} else {
-				s.addToken(SLASH)
+		} else if s.dialect.blockComments && s.nextMatch('*') {
+			s.addBlockComment()
+		} else {
+			s.addOperator()
+		}
+	case '"':
+		s.addString()
+	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1] += 1
+		}
+		s.addOperator()
+	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				// end of the interpolated expression, back into the string
+				s.interpolations = s.interpolations[:n-1]
+				s.addString()
+				break
 			}
-		case '"':
-			// as long as cannot find closing quote
-			for !s.nextMatch('"') {
-				if s.isAtEnd() {
-					s.addError("Unterminated string.")
-					break
-				}
+			s.interpolations[n-1] -= 1
+		}
+		s.addOperator()
+	case ' ': //ignore whitespace
+	case '\t': //ignore tab
+	case '\r': //ignore carriage returns
+	case '\n':
+		s.addLine()
+	default:
+		if unicode.IsDigit(rune(t)) {
+			s.addNumber()
+		} else if s.isAlpha(t) {
+			for s.isAlphaNumeric(s.peek()) {
 				s.advance()
 			}
 
-			// if closing quote matches
-			if s.nextMatch('"') {
-				s.advance()
-				s.addToken(STRING)
-			}
-		case ' ': //ignore whitespace
-		case '\t': //ignore tab
-		case '\r': //ignore carriage returns
-		case '\n':
-			s.addLine()
-		default:
-			if unicode.IsDigit(rune(t)) {
-				s.addNumber()
-			} else if s.isAlpha(t) {
-				for s.isAlphaNumeric(s.peek()) {
-					s.advance()
-				}
-
-				keyword, ok := keywords[string(s.source[s.start:s.current+1])]
-				if ok {
-					s.addToken(keyword)
-				} else {
-					s.addToken(IDENTIFIER)
-				}
+			keyword, ok := s.dialect.keywords[string(s.source[s.start:s.current+1])]
+			if ok {
+				s.addToken(keyword)
 			} else {
-				s.addError(fmt.Sprintf("Unexpected character: %s", string(t)))
+				s.addToken(IDENTIFIER)
 			}
+		} else {
+			s.addOperator()
 		}
-
-		// s.advance()
-		s.start += 1
-		s.current += 1
 	}
 
-	s.addEOF()
+	// s.advance()
+	s.start += 1
+	s.current += 1
 }
 
 func (s *Scanner) GetTokens() []Token {
@@ -337,6 +493,25 @@ func (s *Scanner) GetTokensString() []string {
 	return tokens
 }
 
+// GetErrors returns the errors in the legacy "[line N] Error: message" format
 func (s *Scanner) GetErrors() []string {
+	errors := []string{}
+	for _, e := range s.errors {
+		errors = append(errors, e.Error())
+	}
+
+	return errors
+}
+
+func (s *Scanner) Errors() []ScanError {
 	return s.errors
 }
+
+// Err returns the scan errors as a ScanErrors, or nil if there are none
+func (s *Scanner) Err() error {
+	if len(s.errors) == 0 {
+		return nil
+	}
+
+	return ScanErrors(s.errors)
+}
@@ -0,0 +1,304 @@
+package scanner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTriviaRoundTrip(t *testing.T) {
+	sources := []string{
+		"",
+		"print 1 + 2;",
+		"  // leading comment\nprint \"hi\"; // trailing\n\n",
+		"var a = 1; /* block\ncomment */ a = 2;\n",
+		"1 @ 2 # 3\n",
+		"\"unterminated\n",
+		"/* unterminated",
+		"1.2.3 x\n",
+		"\"a ${ b /* c */ } d\" // e\n",
+	}
+
+	for _, source := range sources {
+		sc := NewScanner([]byte(source), WithTrivia(), WithDialect(NewDialect().AddBlockComments()))
+		sc.Tokenize()
+
+		got := ""
+		for _, token := range sc.GetTokens() {
+			got += token.FullText()
+		}
+
+		if got != source {
+			t.Errorf("round trip of %q = %q", source, got)
+		}
+	}
+}
+
+func TestTriviaPlacement(t *testing.T) {
+	sc := NewScanner([]byte("a; // one\n/* two */ b;"), WithTrivia(), WithDialect(NewDialect().AddBlockComments()))
+	sc.Tokenize()
+	tokens := sc.GetTokens()
+
+	if tokens[1].TrailingTrivia != " // one" {
+		t.Errorf("TrailingTrivia = %q, want %q", tokens[1].TrailingTrivia, " // one")
+	}
+
+	if tokens[2].LeadingTrivia != "\n/* two */ " {
+		t.Errorf("LeadingTrivia = %q, want %q", tokens[2].LeadingTrivia, "\n/* two */ ")
+	}
+}
+
+func TestBlockComment(t *testing.T) {
+	// standard Lox has no block comments, keeping trivia does not add them
+	for _, opts := range [][]Option{nil, {WithTrivia()}} {
+		sc := NewScanner([]byte("1 /* 2"), opts...)
+		sc.Tokenize()
+		if got, want := sc.GetTokensString(), []string{"NUMBER 1 1.0", "SLASH / null", "STAR * null", "NUMBER 2 2.0", "EOF  null"}; !reflect.DeepEqual(got, want) || sc.Err() != nil {
+			t.Errorf("GetTokensString() = %q, %v, want %q", got, sc.Err(), want)
+		}
+	}
+
+	sc := NewScanner([]byte("/* a\n * b */ 1 /* c */ /"), WithDialect(NewDialect().AddBlockComments()))
+	sc.Tokenize()
+
+	want := []string{"NUMBER 1 1.0", "SLASH / null", "EOF  null"}
+	got := sc.GetTokensString()
+	if len(got) != len(want) {
+		t.Fatalf("GetTokensString() = %q, want %q", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetTokensString()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if tokens := sc.GetTokens(); tokens[0].Line != 2 {
+		t.Errorf("Line = %d, want 2", tokens[0].Line)
+	}
+}
+
+func TestWriteTokensJSON(t *testing.T) {
+	sc := NewScanner([]byte("x = \"a,b\";\n  2"))
+	sc.Tokenize()
+
+	var out strings.Builder
+	if err := WriteTokensJSON(&out, sc.GetTokens()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `[
+  {
+    "type": "IDENTIFIER",
+    "lexeme": "x",
+    "literal": null,
+    "line": 1,
+    "column": 1,
+    "offset": 0,
+    "end": 1
+  },
+  {
+    "type": "EQUAL",
+    "lexeme": "=",
+    "literal": null,
+    "line": 1,
+    "column": 3,
+    "offset": 2,
+    "end": 3
+  },
+  {
+    "type": "STRING",
+    "lexeme": "\"a,b\"",
+    "literal": "a,b",
+    "line": 1,
+    "column": 5,
+    "offset": 4,
+    "end": 9
+  },
+  {
+    "type": "SEMICOLON",
+    "lexeme": ";",
+    "literal": null,
+    "line": 1,
+    "column": 10,
+    "offset": 9,
+    "end": 10
+  },
+  {
+    "type": "NUMBER",
+    "lexeme": "2",
+    "literal": 2,
+    "line": 2,
+    "column": 3,
+    "offset": 13,
+    "end": 14
+  },
+  {
+    "type": "EOF",
+    "lexeme": "",
+    "literal": null,
+    "line": 2,
+    "column": 4,
+    "offset": 14,
+    "end": 14
+  }
+]
+`
+	if out.String() != want {
+		t.Errorf("WriteTokensJSON() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteTokensCSV(t *testing.T) {
+	sc := NewScanner([]byte("x = \"a,b\";\n  2"))
+	sc.Tokenize()
+
+	var out strings.Builder
+	if err := WriteTokensCSV(&out, sc.GetTokens()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `type,lexeme,literal,line,column,offset,end
+IDENTIFIER,x,,1,1,0,1
+EQUAL,=,,1,3,2,3
+STRING,"""a,b""","a,b",1,5,4,9
+SEMICOLON,;,,1,10,9,10
+NUMBER,2,2.0,2,3,13,14
+EOF,,,2,4,14,14
+`
+	if out.String() != want {
+		t.Errorf("WriteTokensCSV() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	sc := NewScanner([]byte("1 @\n\"abc"))
+	sc.Tokenize()
+
+	err := sc.Err()
+	if err == nil {
+		t.Fatal("Err() = nil, want errors")
+	}
+
+	if err.Error() != "[line 1] Error: Unexpected character: @\n[line 2] Error: Unterminated string." {
+		t.Errorf("Error() = %q", err.Error())
+	}
+
+	var scanErr ScanError
+	if !errors.As(err, &scanErr) {
+		t.Fatal("errors.As(ScanError) = false")
+	}
+
+	if scanErr.Code != UnexpectedCharacter || scanErr.Code.String() != "E0002 unexpected-character" {
+		t.Errorf("Code = %v", scanErr.Code)
+	}
+
+	errs := sc.Errors()
+	if errs[1].Code.String() != "E0001 unterminated-string" || errs[1].Offset != 4 || errs[1].End != 8 || errs[1].Column != 1 {
+		t.Errorf("Errors()[1] = %+v", errs[1])
+	}
+}
+
+func FuzzRelex(f *testing.F) {
+	f.Add("print 1 + 2;", 6, 1, "10")
+	f.Add("a = b; // comment\nc", 12, 0, "\n")
+	f.Add("x /* y */ z", 3, 1, "")
+	f.Add("\"abc\" d", 0, 1, "")
+	f.Add("1.5 == 2", 5, 0, "=")
+	f.Add("foo\nbar\n  baz", 3, 1, " ")
+
+	f.Fuzz(func(t *testing.T, source string, offset int, deleted int, inserted string) {
+		if offset < 0 || offset > len(source) {
+			return
+		}
+		if deleted < 0 || deleted > len(source)-offset {
+			return
+		}
+
+		sc := NewScanner([]byte(source), WithTrivia())
+		sc.Tokenize()
+		got := sc.Relex(sc.GetTokens(), Edit{Offset: offset, Deleted: deleted, Inserted: inserted})
+
+		edited := source[:offset] + inserted + source[offset+deleted:]
+		full := NewScanner([]byte(edited), WithTrivia())
+		full.Tokenize()
+
+		if !reflect.DeepEqual(got, full.GetTokens()) {
+			t.Fatalf("Relex tokens of %q = %+v, want %+v", edited, got, full.GetTokens())
+		}
+
+		if !reflect.DeepEqual(sc.Errors(), full.Errors()) {
+			t.Fatalf("Relex errors of %q = %+v, want %+v", edited, sc.Errors(), full.Errors())
+		}
+	})
+}
+
+func TestDialect(t *testing.T) {
+	dialect := NewDialect().
+		AddKeyword("let", LET).
+		RemoveKeyword("var").
+		AddOperator("%", PERCENT).
+		AddOperator("**", STAR_STAR).
+		RemoveOperator(".")
+
+	sc := NewScanner([]byte("let var = 2 ** 3 * 4 % 5 ."), WithDialect(dialect))
+	sc.Tokenize()
+
+	want := []string{
+		"LET let null", "IDENTIFIER var null", "EQUAL = null", "NUMBER 2 2.0", "STAR_STAR ** null",
+		"NUMBER 3 3.0", "STAR * null", "NUMBER 4 4.0", "PERCENT % null", "NUMBER 5 5.0", "EOF  null",
+	}
+	got := sc.GetTokensString()
+	if len(got) != len(want) {
+		t.Fatalf("GetTokensString() = %q, want %q", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetTokensString()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if errs := sc.GetErrors(); len(errs) != 1 || errs[0] != "[line 1] Error: Unexpected character: ." {
+		t.Errorf("GetErrors() = %q", errs)
+	}
+}
+
+func TestNewTokenType(t *testing.T) {
+	arrow := NewTokenType("ARROW")
+	sc := NewScanner([]byte("a -> b - c"), WithDialect(NewDialect().AddOperator("->", arrow)))
+	sc.Tokenize()
+
+	if got := sc.GetTokensString()[1]; got != "ARROW -> null" {
+		t.Errorf("GetTokensString()[1] = %q, want %q", got, "ARROW -> null")
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	sc := NewScanner([]byte(`"a ${x + "${1}"} b ${ {} } c"`))
+	sc.Tokenize()
+
+	want := []string{
+		`INTERPOLATION "a ${ a `, "IDENTIFIER x null", "PLUS + null",
+		`INTERPOLATION "${ `, "NUMBER 1 1.0", `STRING }" `,
+		`INTERPOLATION } b ${  b `, "LEFT_BRACE { null", "RIGHT_BRACE } null",
+		`STRING } c"  c`, "EOF  null",
+	}
+	got := sc.GetTokensString()
+	if len(got) != len(want) {
+		t.Fatalf("GetTokensString() = %q, want %q", got, want)
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GetTokensString()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	sc = NewScanner([]byte(`"a ${x`))
+	sc.Tokenize()
+	if errs := sc.GetErrors(); len(errs) != 1 || errs[0] != "[line 1] Error: Unterminated string." {
+		t.Errorf("GetErrors() = %q", errs)
+	}
+}
@@ -0,0 +1,57 @@
+package scanner
+
+// attachTrivia distributes the source text between tokens over the tokens.
+// Everything after a token up to the end of its line becomes its trailing
+// trivia, the rest becomes the leading trivia of the next token. Bytes that
+// did not make it into a token (e.g. unexpected characters) are kept as trivia
+// too, so nothing of the source is lost.
+func (s *Scanner) attachTrivia() {
+	end := 0
+	for i := range s.tokens {
+		gap := string(s.source[end:s.tokens[i].Offset])
+
+		if i == 0 {
+			s.tokens[i].LeadingTrivia = gap
+		} else {
+			split := trailingTriviaLength(gap)
+			s.tokens[i-1].TrailingTrivia = gap[:split]
+			s.tokens[i].LeadingTrivia = gap[split:]
+		}
+
+		end = s.tokens[i].Offset + len(s.tokens[i].Lexeme)
+	}
+}
+
+// trailingTriviaLength returns how much of gap stays on the line of the
+// preceding token, a block comment is never split even if it spans lines
+func trailingTriviaLength(gap string) int {
+	i := 0
+	for i < len(gap) {
+		switch {
+		case gap[i] == '\n':
+			return i
+		case hasPrefixAt(gap, i, "//"):
+			for i < len(gap) && gap[i] != '\n' {
+				i++
+			}
+		case hasPrefixAt(gap, i, "/*"):
+			i += 2
+			for i < len(gap) && !hasPrefixAt(gap, i, "*/") {
+				i++
+			}
+			i += 2
+		default:
+			i++
+		}
+	}
+
+	if i > len(gap) {
+		return len(gap)
+	}
+
+	return i
+}
+
+func hasPrefixAt(s string, i int, prefix string) bool {
+	return len(s)-i >= len(prefix) && s[i:i+len(prefix)] == prefix
+}
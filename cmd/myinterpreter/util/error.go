@@ -23,12 +23,84 @@ func Error(token scanner.Token, message string) string {
 type RuntimeError struct {
 	token   scanner.Token
 	message string
+	kind    RuntimeErrorKind
+	cause   error
+	trace   []Frame // innermost first
 }
 
+// Frame is a call active when a runtime error happened, Line is the line of
+// the call or 0 for a call made by the host
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line"`
+}
+
+func (f Frame) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("at %s", f.Function)
+	}
+
+	if f.File == "" {
+		return fmt.Sprintf("at %s (line %d)", f.Function, f.Line)
+	}
+
+	return fmt.Sprintf("at %s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+// RuntimeErrorKind tells errors of the program apart from the limits the
+// host puts on its execution
+type RuntimeErrorKind int
+
+const (
+	ProgramError   RuntimeErrorKind = iota // the program itself is wrong, like a type error
+	BudgetExceeded                         // the step limit was reached or the context is done
+	OutOfMemory                            // the program allocated more than its memory limit
+)
+
 func NewRuntimeError(token scanner.Token, message string) RuntimeError {
-	return RuntimeError{token: token, message: message}
+	return RuntimeError{token: token, message: message, kind: ProgramError}
+}
+
+// NewBudgetError stops the program at token, cause is the error of the
+// context if it is the reason
+func NewBudgetError(token scanner.Token, cause error) RuntimeError {
+	return RuntimeError{token: token, message: "Execution budget exceeded.", kind: BudgetExceeded, cause: cause}
 }
 
+func NewOutOfMemoryError(token scanner.Token) RuntimeError {
+	return RuntimeError{token: token, message: "Out of memory.", kind: OutOfMemory}
+}
+
+// WithTrace returns the error with the calls active when it happened
+func (r RuntimeError) WithTrace(trace []Frame) RuntimeError {
+	r.trace = trace
+	return r
+}
+
+func (r RuntimeError) Trace() []Frame {
+	return r.trace
+}
+
+func (r RuntimeError) Kind() RuntimeErrorKind {
+	return r.kind
+}
+
+func (r RuntimeError) Unwrap() error {
+	return r.cause
+}
+
+// Error is the message and the line, followed by the stack trace if the
+// error happened in a call. Errors raised outside of the source, like those
+// of calls made by the host, have no line.
 func (r RuntimeError) Error() string {
-	return fmt.Sprintf("%s\n[line %d]", r.message, r.token.Line)
+	str := r.message
+	if r.token.Line > 0 {
+		str += fmt.Sprintf("\n[line %d]", r.token.Line)
+	}
+	for _, frame := range r.trace {
+		str += "\n  " + frame.String()
+	}
+
+	return str
 }